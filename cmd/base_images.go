@@ -0,0 +1,88 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/containers/buildah/pkg/unshare"
+	"github.com/slinkydeveloper/kfn/pkg"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+var baseImagesCmd = &cobra.Command{
+	Use:   "base-images",
+	Short: "Manage the digests the base images are pinned to",
+}
+
+var baseImagesUpdateCmd = &cobra.Command{
+	Use:   "update [image...]",
+	Short: "Resolve again the tags of the pinned base images, of the ones in the config file and of the provided ones, and pin the new digests",
+	RunE:  baseImagesUpdateCmdFn,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
+		return config.InitBuildVariables(cmd, false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(baseImagesCmd)
+	baseImagesCmd.AddCommand(baseImagesUpdateCmd)
+	registryCredentialsFlags(baseImagesUpdateCmd)
+	timeoutFlag(baseImagesUpdateCmd)
+}
+
+func baseImagesUpdateCmdFn(cmd *cobra.Command, args []string) error {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	images := append([]string{}, args...)
+	for _, baseImage := range config.BaseImages {
+		if baseImage != pkg.ScratchImage {
+			images = append(images, baseImage)
+		}
+	}
+	sort.Strings(images)
+
+	updates, err := util.UpdateBaseImages(ctx, config.BuildSystemContext, config.BaseImagesLock, images...)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		fmt.Printf("Base images in %s are up to date\n", config.BaseImagesLock)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tOLD DIGEST\tNEW DIGEST")
+	for _, update := range updates {
+		oldDigest := update.OldDigest
+		if oldDigest == "" {
+			oldDigest = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", update.Name, oldDigest, update.NewDigest)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("Base images pinned in %s, the functions using them are rebuilt by the next build\n", config.BaseImagesLock)
+	return nil
+}
@@ -16,15 +16,17 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"github.com/containers/buildah/pkg/unshare"
 	"github.com/slinkydeveloper/kfn/pkg"
 	"github.com/slinkydeveloper/kfn/pkg/config"
 	"github.com/slinkydeveloper/kfn/pkg/image"
 	"github.com/slinkydeveloper/kfn/pkg/languages"
-	"path"
-	"path/filepath"
-	"strings"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -33,52 +35,221 @@ import (
 
 // buildCmd represents the build command
 var buildCmd = &cobra.Command{
-	Use:   "build <function_name>",
-	Short: "Build the function image",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		buildCmdFn(cmd, args)
+	Use:   "build <function_file_or_directory>...",
+	Short: "Build the function images",
+	Long: `Build the function images. A directory ending with /..., like ./functions/..., matches all the functions inside it
+and its subdirectories, while glob patterns match the function files and directories.
+Many functions are built concurrently, printing a summary of the builds`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		functions, err := expandFunctions(args)
+		if err != nil {
+			return err
+		}
+		if len(functions) == 1 {
+			buildCmdFn(ctx, cmd, functions)
+			return nil
+		}
+		return buildFunctionsCmdFn(ctx, cmd, functions)
 	},
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
-		return config.InitBuildVariables(cmd)
+
+		var err error
+		buildOutputs, err = image.ParseOutputs(outputSpecs)
+		if err != nil {
+			return err
+		}
+		buildPlatforms, err = image.ParsePlatforms(platformSpecs)
+		if err != nil {
+			return err
+		}
+		return config.InitBuildVariables(cmd, image.RequiresRegistry(buildOutputs))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
 	buildFlags(buildCmd)
+	outputFlag(buildCmd)
+	jobsFlag(buildCmd)
 }
 
-func buildCmdFn(cmd *cobra.Command, args []string) image.FunctionImage {
+func buildCmdFn(ctx context.Context, cmd *cobra.Command, args []string) pkg.BuildResult {
 	log.Infof("Using Docker registry: %v\n", config.ImageRegistry)
 
-	functionPath := args[0]
-	functionPath, err := filepath.Abs(functionPath)
+	functionPath, language, err := resolveFunction(args[0])
 	if err != nil {
 		panic(err)
 	}
 
-	language := languages.GetLanguage(path.Ext(functionPath))
-	if language == languages.Unknown {
-		panic(fmt.Sprintf("Unknown language for function %s", functionPath))
-	}
-
 	if len(imageName) == 0 {
-		base := path.Base(functionPath)
-		imageName = strings.TrimSuffix(base, path.Ext(base))
+		imageName = defaultImageName(functionPath)
 	}
 
 	if len(serviceName) == 0 {
 		serviceName = imageName
 	}
 
-	functionImage, err := pkg.Build(functionPath, language, imageName, imageTag, config.BuildSystemContext)
+	result, err := buildFunction(ctx, functionPath, language, imageName, printBuildEvent)
 	if err != nil {
 		panic(fmt.Sprintf("Error while building the image: %v", err))
 	}
 
-	log.Infof("Image %+v pushed", functionImage)
+	log.Infof("Image %+v built", result.Image)
+	for _, platformImage := range result.PlatformImages {
+		log.Infof("Platform %s image %+v", platformImage.Platform, platformImage.Image)
+	}
+
+	return result
+}
+
+// functionBuild is the outcome of the build of one of many functions
+type functionBuild struct {
+	function string
+	result   pkg.BuildResult
+	err      error
+	duration time.Duration
+}
+
+// buildFunctionsCmdFn builds the functions concurrently, running at most --jobs builds at the same time.
+// The images are named after the functions
+func buildFunctionsCmdFn(ctx context.Context, cmd *cobra.Command, functions []string) error {
+	if imageName != "" {
+		return fmt.Errorf("--imageName can't be used with many functions, the images are named after the functions")
+	}
+	if jobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+
+	log.Infof("Using Docker registry: %v\n", config.ImageRegistry)
+
+	functionPaths := make([]string, len(functions))
+	functionLanguages := make([]languages.Language, len(functions))
+	names := make(map[string]string, len(functions))
+	for i, function := range functions {
+		functionPath, language, err := resolveFunction(function)
+		if err != nil {
+			return fmt.Errorf("%s: %v", function, err)
+		}
+		name := defaultImageName(functionPath)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("the functions %s and %s have the same image name %s", other, function, name)
+		}
+		names[name] = function
+		functionPaths[i], functionLanguages[i] = functionPath, language
+	}
+
+	// The arguments are valid, the build failures are reported in the summary
+	cmd.SilenceUsage = true
+
+	builds := make([]functionBuild, len(functions))
+	semaphore := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i := range functions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			build := &builds[i]
+			build.function = functions[i]
+			start := time.Now()
+			build.result, build.err = buildFunction(ctx, functionPaths[i], functionLanguages[i], defaultImageName(functionPaths[i]), functionEventPrinter(functions[i]))
+			build.duration = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+
+	return printBuildSummary(builds)
+}
+
+// printBuildSummary prints the result, the image digest and the duration of every build, returning an error if any failed
+func printBuildSummary(builds []functionBuild) error {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FUNCTION\tRESULT\tDIGEST\tDURATION")
+	for _, build := range builds {
+		result, digest := "built", build.result.Image.Digest
+		if build.err != nil {
+			result, digest = "failed", ""
+			failed++
+		} else if build.result.Skipped {
+			result = "unchanged"
+		}
+		if digest == "" {
+			digest = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", build.function, result, digest, build.duration.Round(time.Millisecond))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, build := range builds {
+		if build.err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", build.function, build.err)
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d functions failed", failed, len(builds))
+	}
+	return nil
+}
+
+// buildFunction builds the function with the options of the build flags
+func buildFunction(ctx context.Context, functionPath string, language languages.Language, name string, onEvent func(pkg.BuildEvent)) (pkg.BuildResult, error) {
+	return pkg.Build(ctx, pkg.BuildOptions{
+		Location:       functionPath,
+		Entry:          entryName,
+		Language:       language,
+		ImageName:      name,
+		ImageTag:       imageTag,
+		Force:          forceBuild,
+		Outputs:        buildOutputs,
+		Platforms:      buildPlatforms,
+		Reproducible:   config.Reproducible,
+		SourceDate:     config.SourceDate,
+		SBOMFormat:     config.SBOMFormat,
+		SBOMAttach:     config.SBOMAttach,
+		SigningKey:     config.SigningKey,
+		BaseImages:     configuredBaseImages(),
+		BaseImagesLock: config.BaseImagesLock,
+		SystemContext:  config.BuildSystemContext,
+		OnEvent:        onEvent,
+	})
+}
+
+// Prints the build progress on stderr, so it's visible even when the logs are disabled
+func printBuildEvent(event pkg.BuildEvent) {
+	printPrefixedBuildEvent("", event)
+}
 
-	return functionImage
+// functionEventPrinter prints the build progress prefixed by the function, to tell apart the concurrent builds
+func functionEventPrinter(function string) func(pkg.BuildEvent) {
+	return func(event pkg.BuildEvent) {
+		printPrefixedBuildEvent("["+function+"] ", event)
+	}
+}
+
+func printPrefixedBuildEvent(prefix string, event pkg.BuildEvent) {
+	stage := string(event.Stage)
+	if !event.Platform.IsDefault() {
+		stage += " " + event.Platform.String()
+	}
+
+	switch event.Type {
+	case pkg.StageStarted:
+		fmt.Fprintf(os.Stderr, "%s[%s] started\n", prefix, stage)
+	case pkg.StageCompleted:
+		fmt.Fprintf(os.Stderr, "%s[%s] completed in %s\n", prefix, stage, event.Duration.Round(time.Millisecond))
+	case pkg.StageFailed:
+		fmt.Fprintf(os.Stderr, "%s[%s] failed after %s: %v\n", prefix, stage, event.Duration.Round(time.Millisecond), event.Error)
+	case pkg.StageSkipped:
+		fmt.Fprintf(os.Stderr, "%s[%s] skipped: %s\n", prefix, stage, event.Message)
+	}
 }
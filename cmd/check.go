@@ -0,0 +1,110 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/containers/buildah/pkg/unshare"
+	"github.com/slinkydeveloper/kfn/pkg"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/spf13/cobra"
+)
+
+// Tag of the images built by the checks, so they don't replace the images built by kfn build
+const checkImageTag = "reproducibility-check"
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check properties of the function build",
+}
+
+var checkReproducibleCmd = &cobra.Command{
+	Use:   "reproducible <function_file_or_directory>",
+	Short: "Build the function twice in reproducible mode and compare the image digests",
+	Args:  cobra.ExactArgs(1),
+	RunE:  checkReproducibleCmdFn,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
+		return config.InitBuildVariables(cmd, false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.AddCommand(checkReproducibleCmd)
+	registryCredentialsFlags(checkReproducibleCmd)
+	languageFlag(checkReproducibleCmd)
+	entryFlag(checkReproducibleCmd)
+	timeoutFlag(checkReproducibleCmd)
+	compileFlags(checkReproducibleCmd)
+	platformFlag(checkReproducibleCmd)
+}
+
+func checkReproducibleCmdFn(cmd *cobra.Command, args []string) error {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	functionPath, language, err := resolveFunction(args[0])
+	if err != nil {
+		return err
+	}
+
+	platforms, err := image.ParsePlatforms(platformSpecs)
+	if err != nil {
+		return err
+	}
+
+	checks, err := pkg.CheckReproducible(ctx, pkg.BuildOptions{
+		Location:       functionPath,
+		Entry:          entryName,
+		Language:       language,
+		ImageName:      defaultImageName(functionPath),
+		ImageTag:       checkImageTag,
+		Platforms:      platforms,
+		SourceDate:     config.SourceDate,
+		BaseImages:     configuredBaseImages(),
+		BaseImagesLock: config.BaseImagesLock,
+		SystemContext:  config.BuildSystemContext,
+		OnEvent:        printBuildEvent,
+	})
+	if err != nil {
+		return err
+	}
+
+	reproducible := true
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PLATFORM\tFIRST BUILD\tSECOND BUILD\tRESULT")
+	for _, check := range checks {
+		result := "reproducible"
+		if !check.Reproducible() {
+			result = "different"
+			reproducible = false
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", check.Platform, check.First.ID, check.Second.ID, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !reproducible {
+		return fmt.Errorf("the builds of %s produced different images", args[0])
+	}
+	return nil
+}
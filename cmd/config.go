@@ -0,0 +1,79 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config <function_file_or_directory>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Print the effective function configuration, merging the kfn: comments with the sidecar file",
+	RunE:  configCmdFn,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	languageFlag(configCmd)
+	entryFlag(configCmd)
+}
+
+func configCmdFn(cmd *cobra.Command, args []string) error {
+	functionPath, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	function, err := languages.ResolveFunction(functionPath, entryName)
+	if err != nil {
+		return err
+	}
+
+	language, err := languages.ResolveLanguage(function.MainFile, languageName)
+	if err != nil {
+		return err
+	}
+
+	functionConfiguration, err := languages.LoadConfiguration(language, function)
+	if err != nil {
+		return err
+	}
+
+	sidecar := languages.SidecarFile(function)
+	if !util.FileExist(sidecar) {
+		sidecar += " (not found)"
+	}
+
+	fmt.Printf("Language: %s\n", language)
+	fmt.Printf("Sidecar file: %s\n\n", sidecar)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+	for _, k := range functionConfiguration.Keys() {
+		for _, e := range functionConfiguration.Entries(k) {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k, e.Value(), e.Position())
+		}
+	}
+	return w.Flush()
+}
@@ -17,7 +17,6 @@ package cmd
 
 import (
 	"fmt"
-	"path"
 	"path/filepath"
 
 	"github.com/slinkydeveloper/kfn/pkg/config"
@@ -37,6 +36,8 @@ var editCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(editCmd)
+	languageFlag(editCmd)
+	entryFlag(editCmd)
 }
 
 func editCmdFn(cmd *cobra.Command, args []string) error {
@@ -46,11 +47,16 @@ func editCmdFn(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	editingDir := config.GetEditingDir(functionPath)
+	function, err := languages.ResolveFunction(functionPath, entryName)
+	if err != nil {
+		return err
+	}
 
-	language := languages.GetLanguage(path.Ext(functionPath))
-	if language == languages.Unknown {
-		return fmt.Errorf("Unknown language for function %s", functionPath)
+	editingDir := config.GetEditingDir(function.Location())
+
+	language, err := languages.ResolveLanguage(function.MainFile, languageName)
+	if err != nil {
+		return err
 	}
 
 	ed := editors.GetEditor(args[1])
@@ -63,12 +69,12 @@ func editCmdFn(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	functionConfiguration, err := util.ParseConfigComments(languages.GetLineComment(language), functionPath)
+	functionConfiguration, err := languages.LoadConfiguration(language, function)
 	if err != nil {
 		return err
 	}
 
-	directory, err := language.ConfigureEditingDirectory(functionPath, functionConfiguration, editingDir)
+	directory, err := language.ConfigureEditingDirectory(function, functionConfiguration, editingDir)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,91 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/containers/buildah/pkg/unshare"
+	"github.com/slinkydeveloper/kfn/pkg"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the function build to other tools",
+}
+
+var exportContainerfileCmd = &cobra.Command{
+	Use:   "containerfile <function_file_or_directory> <destination_directory>",
+	Short: "Compile the function and write a Containerfile building its image, together with the files it requires",
+	Args:  cobra.ExactArgs(2),
+	RunE:  exportContainerfileCmdFn,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
+		return config.InitBuildVariables(cmd, false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportContainerfileCmd)
+	languageFlag(exportContainerfileCmd)
+	entryFlag(exportContainerfileCmd)
+	timeoutFlag(exportContainerfileCmd)
+	compileFlags(exportContainerfileCmd)
+	platformFlag(exportContainerfileCmd)
+}
+
+func exportContainerfileCmdFn(cmd *cobra.Command, args []string) error {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	functionPath, language, err := resolveFunction(args[0])
+	if err != nil {
+		return err
+	}
+
+	destination, err := filepath.Abs(args[1])
+	if err != nil {
+		return err
+	}
+
+	platforms, err := image.ParsePlatforms(platformSpecs)
+	if err != nil {
+		return err
+	}
+
+	err = pkg.ExportContainerfile(ctx, pkg.BuildOptions{
+		Location:       functionPath,
+		Entry:          entryName,
+		Language:       language,
+		Platforms:      platforms,
+		BaseImages:     configuredBaseImages(),
+		BaseImagesLock: config.BaseImagesLock,
+		SystemContext:  config.BuildSystemContext,
+		OnEvent:        printBuildEvent,
+	}, destination)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Build the image with: buildah bud -f %s %s\n", filepath.Join(destination, util.Containerfile), destination)
+	return nil
+}
@@ -17,41 +17,118 @@ package cmd
 
 import (
 	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"runtime"
 	"strings"
+	"time"
 )
 
 var (
 	imageName string
 	imageTag string
 	serviceName string
+	languageName string
+	entryName string
+	forceBuild bool
+	timeout time.Duration
+	jobs int
+	outputSpecs []string
+	buildOutputs []image.Output
+	platformSpecs []string
+	buildPlatforms []image.Platform
+	// Config keys of the flags bound to viper, by flag name
+	boundFlags = map[string]string{}
 )
 
 func stringFlagWithBind(flagSet *pflag.FlagSet, envName, shorthandFlag, defaultValue, usage string) {
 	flagName := strings.ReplaceAll(envName, "_", "-")
 	flagSet.StringP(flagName, shorthandFlag, defaultValue, usage)
 	_ = viper.BindPFlag(envName, flagSet.Lookup(flagName))
+	boundFlags[flagName] = envName
 }
 
 func boolFlagWithBind(flagSet *pflag.FlagSet, envName string, shorthandFlag string, defaultValue bool, usage string) {
 	flagName := strings.ReplaceAll(envName, "_", "-")
 	flagSet.BoolP(flagName, shorthandFlag, defaultValue, usage)
 	_ = viper.BindPFlag(envName, flagSet.Lookup(flagName))
+	boundFlags[flagName] = envName
+}
+
+// bindFlags binds the config keys to the flags of the executed command. Viper binds a key to a single flag,
+// the last one registered, while the same flags are registered by many commands
+func bindFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if key, ok := boundFlags[flag.Name]; ok {
+			_ = viper.BindPFlag(key, flag)
+		}
+	})
 }
 
 func buildFlags(cmd *cobra.Command) {
 	stringFlagWithBind(cmd.Flags(), config.REGISTRY, "", "", "Docker registry where to push the image")
-	stringFlagWithBind(cmd.Flags(), config.REGISTRY_USERNAME, "", "", "Username to access docker registry")
-	stringFlagWithBind(cmd.Flags(), config.REGISTRY_PASSWORD, "", "", "Password to access docker registry")
-	boolFlagWithBind(cmd.Flags(), config.REGISTRY_TLS_VERIFY, "", true, "TLS Verify when accessing the docker registry")
+	registryCredentialsFlags(cmd)
 	cmd.Flags().StringVarP(&imageName, "imageName", "i", "", "Image name")
 	cmd.Flags().StringVarP(&imageTag, "imageTag", "t", "", "Image tag")
 	cmd.Flags().StringVarP(&serviceName, "serviceName", "s", "", "KNative service name")
+	languageFlag(cmd)
+	entryFlag(cmd)
+	cmd.Flags().BoolVar(&forceBuild, "force", false, "Build the function even if it didn't change since the last build")
+	boolFlagWithBind(cmd.Flags(), config.REPRODUCIBLE, "", false, "Build a reproducible image, using SOURCE_DATE_EPOCH as timestamp of the files and of the image")
+	stringFlagWithBind(cmd.Flags(), config.SBOM_FORMAT, "", "cyclonedx", "Format of the SBOM written in the target directory: cyclonedx, spdx or none")
+	boolFlagWithBind(cmd.Flags(), config.SBOM_ATTACH, "", false, "Attach the SBOM to the pushed image")
+	stringFlagWithBind(cmd.Flags(), config.SIGNING_KEY, "", "", "Private key signing the pushed image, generated with kfn generate-key")
+	timeoutFlag(cmd)
+	compileFlags(cmd)
+	platformFlag(cmd)
+}
+
+func registryCredentialsFlags(cmd *cobra.Command) {
+	stringFlagWithBind(cmd.Flags(), config.REGISTRY_USERNAME, "", "", "Username to access docker registry")
+	stringFlagWithBind(cmd.Flags(), config.REGISTRY_PASSWORD, "", "", "Password to access docker registry")
+	boolFlagWithBind(cmd.Flags(), config.REGISTRY_TLS_VERIFY, "", true, "TLS Verify when accessing the docker registry")
+}
+
+func compileFlags(cmd *cobra.Command) {
+	rustBuilderFlags(cmd)
+	boolFlagWithBind(cmd.Flags(), config.OFFLINE, "", false, "Build without network access, using the runtimes and the dependencies vendored with kfn vendor")
+}
+
+func rustBuilderFlags(cmd *cobra.Command) {
+	stringFlagWithBind(cmd.Flags(), config.RUST_BUILDER_IMAGE, "", config.DefaultRustBuilderImage, "Image used to compile Rust functions")
+	boolFlagWithBind(cmd.Flags(), config.RUST_HOST_BUILD, "", false, "Compile Rust functions with the toolchain installed on the host instead of the builder image")
+}
+
+func languageFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&languageName, "language", "l", "", "Function language, overrides the detected one")
+}
+
+func outputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&outputSpecs, "output", "o", nil, "Destination of the image: registry, containers-storage, oci-archive:<path>, docker-archive:<path> or oci:<path>. Can be repeated, defaults to registry")
+}
+
+func platformFlag(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&platformSpecs, "platform", nil, "Platforms of the image in the form os/arch[/variant], like linux/amd64,linux/arm64. With more than one platform the tag points to a manifest list")
+}
+
+func jobsFlag(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "Maximum number of functions built concurrently")
+}
+
+func timeoutFlag(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Maximum duration of the command, like 10m. 0 means no timeout")
+}
+
+func entryFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&entryName, "entry", "e", "", "Entry file of a directory function, relative to the directory")
 }
 
 func runFlags(cmd *cobra.Command) {
 	stringFlagWithBind(cmd.Flags(), config.KUBECONFIG, "", "", "Kubeconfig")
 	stringFlagWithBind(cmd.Flags(), config.NAMESPACE, "", "default", "K8s namespace where to run the service")
+	stringFlagWithBind(cmd.Flags(), config.SIGNATURE_POLICY, "", "warn", "What to do when the image has no valid signature before deploying it: enforce, warn or disabled")
+	stringFlagWithBind(cmd.Flags(), config.TRUSTED_KEYS, "", "", "Comma separated public keys trusted to sign the images")
+	boolFlagWithBind(cmd.Flags(), config.TRUST_SIGNING_KEY, "", false, "Trust the images signed with the signing key too")
 }
\ No newline at end of file
@@ -0,0 +1,124 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+)
+
+// resolveFunction returns the absolute location of the function and its language.
+// Remote functions are downloaded while building, so their language is detected later if not specified
+func resolveFunction(functionPath string) (string, languages.Language, error) {
+	if strings.HasPrefix(functionPath, "http") {
+		if languageName == "" {
+			return functionPath, languages.Unknown, nil
+		}
+		language := languages.GetLanguageByName(languageName)
+		if language == languages.Unknown {
+			return "", languages.Unknown, fmt.Errorf("Unknown language %s", languageName)
+		}
+		return functionPath, language, nil
+	}
+
+	functionPath, err := filepath.Abs(functionPath)
+	if err != nil {
+		return "", languages.Unknown, err
+	}
+
+	function, err := languages.ResolveFunction(functionPath, entryName)
+	if err != nil {
+		return "", languages.Unknown, err
+	}
+
+	language, err := languages.ResolveLanguage(function.MainFile, languageName)
+	if err != nil {
+		return "", languages.Unknown, err
+	}
+	return functionPath, language, nil
+}
+
+// expandFunctions resolves the function arguments: a path ending with /... matches the functions in the directory
+// and its subdirectories, a glob pattern the matching files and directories. Remote functions are kept as they are
+func expandFunctions(args []string) ([]string, error) {
+	var functions []string
+	seen := make(map[string]bool)
+	add := func(function string) {
+		if !seen[function] {
+			seen[function] = true
+			functions = append(functions, function)
+		}
+	}
+
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "http"):
+			add(arg)
+		case arg == "..." || strings.HasSuffix(arg, "/..."):
+			root := path.Clean(strings.TrimSuffix(arg, "..."))
+			found, err := languages.FindFunctions(root)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 0 {
+				return nil, fmt.Errorf("no functions found in %s", root)
+			}
+			for _, function := range found {
+				add(function)
+			}
+		case strings.ContainsAny(arg, "*?["):
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no functions match %s", arg)
+			}
+			for _, function := range matches {
+				add(function)
+			}
+		default:
+			add(filepath.Clean(arg))
+		}
+	}
+	return functions, nil
+}
+
+// defaultImageName is the name of the function file or directory, without the extension
+func defaultImageName(functionPath string) string {
+	base := path.Base(functionPath)
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// configuredBaseImages returns the base images configured in the config file, by language
+func configuredBaseImages() map[languages.Language]string {
+	baseImages := make(map[languages.Language]string, len(config.BaseImages))
+	for name, baseImage := range config.BaseImages {
+		language := languages.GetLanguageByName(name)
+		if language == languages.Unknown {
+			log.Warnf("Ignoring the base image of the unknown language %s", name)
+			continue
+		}
+		baseImages[language] = baseImage
+	}
+	return baseImages
+}
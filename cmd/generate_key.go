@@ -0,0 +1,42 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/slinkydeveloper/kfn/pkg/signature"
+	"github.com/spf13/cobra"
+)
+
+// generateKeyCmd represents the generate-key command
+var generateKeyCmd = &cobra.Command{
+	Use:   "generate-key <key_file>",
+	Short: "Generate the key signing the images, writing the private key to key_file and the public key to key_file.pub",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		public, err := signature.GenerateKey(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Key %s generated, the public key is %s.pub\n", signature.KeyID(public), args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generateKeyCmd)
+}
@@ -23,22 +23,26 @@ import (
 
 // initCmd represents the init parent command
 var InitCmd = &cobra.Command{
-	Use:   "init",
+	Use:   "init <language> [function_name] [directory]",
 	Short: "Init your function",
+	Long:  "Init your function. Languages provided by plugins can be used as <language>",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE:  initPluginCmdFn,
 }
 
 func init() {
-	InitCmd.AddCommand(newInitCmd("js", "Javascript", languages.Javascript))
-	InitCmd.AddCommand(newInitCmd("rust", "Rust", languages.Rust))
+	for _, d := range languages.Descriptors() {
+		InitCmd.AddCommand(newInitCmd(d))
+	}
 	rootCmd.AddCommand(InitCmd)
 }
 
-func newInitCmd(languageCmdName string, languageLongName string, language languages.Language) *cobra.Command {
+func newInitCmd(descriptor languages.Descriptor) *cobra.Command {
 	return &cobra.Command{
-		Use:   fmt.Sprintf("%s [function_name] [directory]", languageCmdName),
+		Use:   fmt.Sprintf("%s [function_name] [directory]", descriptor.Name),
 		Args:  cobra.MaximumNArgs(2),
-		Short: fmt.Sprintf("Bootstrap a %s function", languageLongName),
-		RunE:  newInitCmdFn(language),
+		Short: fmt.Sprintf("Bootstrap a %s function", descriptor.LongName),
+		RunE:  newInitCmdFn(descriptor.Name),
 	}
 }
 
@@ -48,6 +52,15 @@ func newInitCmdFn(language languages.Language) func(cmd *cobra.Command, args []s
 	}
 }
 
+// Languages provided by plugins are discovered after the commands are created, so they are resolved here
+func initPluginCmdFn(cmd *cobra.Command, args []string) error {
+	language := languages.GetLanguageByName(args[0])
+	if language == languages.Unknown {
+		return fmt.Errorf("Unknown language %s", args[0])
+	}
+	return language.Bootstrap(resolveFunctionAndDir(args[1:]))
+}
+
 func resolveFunctionAndDir(args []string) (string, string) {
 	if len(args) == 2 {
 		return args[0], args[1]
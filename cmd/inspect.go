@@ -0,0 +1,106 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/containers/buildah/pkg/unshare"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectJSON bool
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <image>",
+	Short: "Show the function metadata stamped on an image, looking in the local storage first and then in the registry",
+	Args:  cobra.ExactArgs(1),
+	RunE:  inspectCmdFn,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
+		return config.InitBuildVariables(cmd, false)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+	registryCredentialsFlags(inspectCmd)
+	timeoutFlag(inspectCmd)
+	inspectCmd.Flags().BoolVar(&inspectJSON, "json", false, "Print the metadata as json")
+}
+
+func inspectCmdFn(cmd *cobra.Command, args []string) error {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	info, err := util.InspectImage(ctx, config.BuildSystemContext, args[0])
+	if err != nil {
+		return err
+	}
+	if !image.IsFunctionImage(info.Labels) {
+		return fmt.Errorf("image %s was not built by kfn", args[0])
+	}
+
+	metadata := image.MetadataFromLabels(info.Labels)
+	if inspectJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(metadata)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	printField := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", name, value)
+		}
+	}
+	printField("Function", metadata.Function)
+	printField("Language", metadata.Language)
+	printField("Platform", info.Os+"/"+info.Architecture)
+	if !metadata.Created.IsZero() {
+		printField("Created", metadata.Created.Format(time.RFC3339))
+	}
+	printField("Source", metadata.Source)
+	printField("Revision", metadata.Revision)
+	printField("Source hash", metadata.SourceHash)
+	printField("Runtime hash", metadata.RuntimeHash)
+	printField("Build hash", metadata.BuildHash)
+	printField("Dependencies", formatDependencies(metadata.Dependencies))
+	printField("Base image", metadata.BaseImage)
+	printField("kfn version", metadata.KfnVersion)
+	return w.Flush()
+}
+
+func formatDependencies(deps map[string]string) string {
+	entries := make([]string, 0, len(deps))
+	for name, version := range deps {
+		entries = append(entries, name+" "+version)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ", ")
+}
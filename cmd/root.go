@@ -30,6 +30,7 @@ var rootCmd = &cobra.Command{
 	Short: "TODO",
 	Long:  `TODO`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		bindFlags(cmd)
 		config.InitLogging()
 		config.InitKfnDir()
 	},
@@ -19,8 +19,12 @@ import (
 	"fmt"
 	"github.com/containers/buildah/pkg/unshare"
 	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg"
 	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/dynamic"
 	serving "knative.dev/serving/pkg/client/clientset/versioned"
 )
 
@@ -33,8 +37,16 @@ var runCmd = &cobra.Command{
 	Run:   runCmdFn,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
-		config.InitRunVariables()
-		return config.InitBuildVariables(cmd)
+		if err := config.InitRunVariables(); err != nil {
+			return err
+		}
+
+		var err error
+		buildPlatforms, err = image.ParsePlatforms(platformSpecs)
+		if err != nil {
+			return err
+		}
+		return config.InitBuildVariables(cmd, true)
 	},
 }
 
@@ -45,7 +57,11 @@ func init() {
 }
 
 func runCmdFn(cmd *cobra.Command, args []string) {
-	functionImage := buildCmdFn(cmd, args)
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	result := buildCmdFn(ctx, cmd, args)
+	functionImage, functionConfiguration := result.Image, result.Configuration
 
 	log.Infof("Image %s pushed", functionImage.ImageName)
 
@@ -59,8 +75,33 @@ func runCmdFn(cmd *cobra.Command, args []string) {
 	if err != nil {
 		panic(fmt.Sprintf("Cannot create a serving client: %+v", err))
 	}
+	eventingClient, err := dynamic.NewForConfig(kconfig)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot create an eventing client: %+v", err))
+	}
+
+	serviceOptions := image.ServiceOptions{
+		Env:      functionConfiguration.Strings(languages.EnvKey),
+		MinScale: functionConfiguration.Int(languages.MinScaleKey, 0),
+		MaxScale: functionConfiguration.Int(languages.MaxScaleKey, 0),
+		Triggers: functionConfiguration.Triggers(),
+	}
+
+	// Copy the configured keys, so appending the signing key doesn't touch them
+	trustedKeys := append([]string{}, config.TrustedKeys...)
+	if config.TrustSigningKey && config.SigningKey != "" {
+		trustedKeys = append(trustedKeys, config.SigningKey)
+	}
+	functionImage, err = pkg.VerifyImage(ctx, pkg.VerifyOptions{
+		Policy:        config.SignaturePolicy,
+		TrustedKeys:   trustedKeys,
+		SystemContext: config.BuildSystemContext,
+	}, functionImage)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot deploy the service: %v", err))
+	}
 
-	err = functionImage.RunImage(servingClient.ServingV1alpha1(), serviceName, config.Namespace)
+	err = functionImage.RunImage(ctx, servingClient.ServingV1alpha1(), eventingClient, serviceName, config.Namespace, serviceOptions)
 
 	if err != nil {
 		panic(fmt.Sprintf("Cannot deploy the service: %+v", err))
@@ -0,0 +1,109 @@
+/*
+Copyright © 2019 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/containers/buildah/pkg/unshare"
+	"github.com/slinkydeveloper/kfn/pkg"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var vendorCmd = &cobra.Command{
+	Use:   "vendor <function_file_or_directory>...",
+	Short: "Download the runtimes and the dependencies of the functions to the kfn directory, to build them with --offline",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  vendorCmdFn,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		unshare.MaybeReexecUsingUserNamespace(false) // Do crazy stuff that allows buildah to work
+		return config.InitBuildVariables(cmd, false)
+	},
+}
+
+var vendorExportCmd = &cobra.Command{
+	Use:   "export <archive>",
+	Short: "Write the vendored runtimes and dependencies to a tar.gz archive",
+	Args:  cobra.ExactArgs(1),
+	RunE:  vendorExportCmdFn,
+}
+
+var vendorImportCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Extract to the kfn directory the runtimes and the dependencies of an archive written by kfn vendor export",
+	Args:  cobra.ExactArgs(1),
+	RunE:  vendorImportCmdFn,
+}
+
+func init() {
+	rootCmd.AddCommand(vendorCmd)
+	vendorCmd.AddCommand(vendorExportCmd)
+	vendorCmd.AddCommand(vendorImportCmd)
+	registryCredentialsFlags(vendorCmd)
+	languageFlag(vendorCmd)
+	entryFlag(vendorCmd)
+	timeoutFlag(vendorCmd)
+	rustBuilderFlags(vendorCmd)
+}
+
+func vendorCmdFn(cmd *cobra.Command, args []string) error {
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	for _, arg := range args {
+		functionPath, language, err := resolveFunction(arg)
+		if err != nil {
+			return err
+		}
+
+		err = pkg.Vendor(ctx, pkg.BuildOptions{
+			Location:      functionPath,
+			Entry:         entryName,
+			Language:      language,
+			SystemContext: config.BuildSystemContext,
+			OnEvent:       printBuildEvent,
+		})
+		if err != nil {
+			return fmt.Errorf("cannot vendor the dependencies of %s: %v", arg, err)
+		}
+	}
+
+	fmt.Printf("Dependencies vendored in %s, export them with: kfn vendor export <archive>\n", config.VendorDir)
+	return nil
+}
+
+func vendorExportCmdFn(cmd *cobra.Command, args []string) error {
+	archive, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if err := pkg.ExportVendor(archive); err != nil {
+		return err
+	}
+	fmt.Printf("Vendored runtimes and dependencies written to %s\n", archive)
+	return nil
+}
+
+func vendorImportCmdFn(cmd *cobra.Command, args []string) error {
+	files, err := pkg.ImportVendor(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d files to %s, build the functions with --offline\n", files, config.KfnDir)
+	return nil
+}
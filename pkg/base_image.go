@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+// ScratchImage configures an empty base image, like FROM scratch
+const ScratchImage = "scratch"
+
+// pinnedBaseImage is the base image of the function image, before and after pinning it to a digest
+type pinnedBaseImage struct {
+	name   string
+	pinned string
+}
+
+// configuredBaseImage returns the base image configured for the function, empty when the language one is used
+func (fn preparedFunction) configuredBaseImage(options BuildOptions) string {
+	return fn.configuration.String(languages.BaseImageKey, options.BaseImages[fn.language])
+}
+
+// withBaseImage replaces the base image of the recipe, the one of its innermost base stage, with the configured one,
+// then pins it to the digest recorded in the base images lock
+func (fn preparedFunction) withBaseImage(ctx context.Context, options BuildOptions, recipe util.ImageRecipe) (util.ImageRecipe, pinnedBaseImage, error) {
+	root := rootRecipe(&recipe)
+
+	base := pinnedBaseImage{name: root.BaseImage}
+	if configured := fn.configuredBaseImage(options); configured == ScratchImage {
+		base.name = ""
+	} else if configured != "" {
+		base.name = configured
+	}
+
+	base.pinned = base.name
+	if options.BaseImagesLock != "" {
+		var err error
+		base.pinned, err = util.PinBaseImage(ctx, options.SystemContext, options.BaseImagesLock, base.name)
+		if err != nil {
+			return util.ImageRecipe{}, pinnedBaseImage{}, err
+		}
+	}
+
+	root.BaseImage = base.pinned
+	return recipe, base, nil
+}
+
+// rootRecipe returns the innermost base stage of the recipe, copying the stages so the recipe returned by the language is not modified
+func rootRecipe(recipe *util.ImageRecipe) *util.ImageRecipe {
+	for recipe.BaseStage != nil {
+		stage := *recipe.BaseStage
+		recipe.BaseStage = &stage
+		recipe = recipe.BaseStage
+	}
+	return recipe
+}
@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"context"
+	"time"
+
+	"github.com/containers/image/types"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+)
+
+// BuildOptions configures a function build
+type BuildOptions struct {
+	// Function file, directory or http url
+	Location string
+	// Entry file of a directory function, relative to the directory. When empty it's resolved
+	// looking for the conventional entry files of the languages
+	Entry string
+	// Function language, detected when Unknown
+	Language  languages.Language
+	ImageName string
+	ImageTag  string
+	// Build even if the function didn't change since the last build
+	Force bool
+	// Destinations of the built image. When empty the image is pushed to the registry
+	Outputs []image.Output
+	// Platforms of the image. With more than one platform, an image is built and pushed for each platform
+	// and the tag points to a manifest list. When empty the image is built for the default platform
+	Platforms []image.Platform
+	// Reproducible builds pin the base image by digest, normalize the timestamps and the ownership of the image files
+	// and set the image creation time to SourceDate, so building the same inputs produces the same image
+	Reproducible bool
+	// SourceDate is the timestamp of the reproducible builds, usually read from SOURCE_DATE_EPOCH.
+	// When zero the unix epoch is used
+	SourceDate time.Time
+	// Format of the SBOM written in the target directory, CycloneDX when empty. sbom.None disables it
+	SBOMFormat sbom.Format
+	// Attach the SBOM to the pushed image, as an artifact tagged sha256-<image digest hex>.sbom
+	SBOMAttach bool
+	// Base images per language, replacing the default ones. The kfn:base-image of the function takes precedence
+	BaseImages map[languages.Language]string
+	// File recording the digests the tags of the base images resolved to. The tags are resolved at the first build
+	// and the recorded digests are used until they're updated, like with kfn base-images update.
+	// When empty the base images are not pinned
+	BaseImagesLock string
+	// Private key signing the digest of the pushed image, generated with kfn generate-key. When empty the image is not signed
+	SigningKey    string
+	SystemContext *types.SystemContext
+	// OnEvent receives the progress of the build, it can be nil.
+	// It's invoked synchronously from the goroutine running the build
+	OnEvent func(BuildEvent)
+
+	// Platform of the stages currently running, reported in the events
+	platform image.Platform
+	// Rebuild the cached stages, used to check the reproducibility
+	noCache bool
+}
+
+type BuildResult struct {
+	Image image.FunctionImage
+	// Function configuration, merged with the sidecar file
+	Configuration languages.Configuration
+	// Skipped is true when the function didn't change and the previous image was reused
+	Skipped bool
+	// Images of the single platforms of a multi-architecture build
+	PlatformImages []image.PlatformImage
+}
+
+// BuildStage is a step of the build
+type BuildStage string
+
+const (
+	StageDownloadFunction BuildStage = "download-function"
+	StageRuntimeDownload  BuildStage = "runtime-download"
+	StageConfiguration    BuildStage = "configuration"
+	StageDependencyCheck  BuildStage = "dependency-check"
+	StageConfigure        BuildStage = "configure"
+	StageCompile          BuildStage = "compile"
+	StageImageBuild       BuildStage = "image-build"
+	StagePush             BuildStage = "push"
+	StageExport           BuildStage = "export"
+	StageManifestList     BuildStage = "manifest-list"
+	StageSBOM             BuildStage = "sbom"
+	StageSign             BuildStage = "sign"
+	StageVendor           BuildStage = "vendor"
+)
+
+type BuildEventType string
+
+const (
+	StageStarted   BuildEventType = "started"
+	StageCompleted BuildEventType = "completed"
+	StageFailed    BuildEventType = "failed"
+	// StageSkipped is emitted for the stages not executed, like when the previous image is reused
+	StageSkipped BuildEventType = "skipped"
+)
+
+type BuildEvent struct {
+	Type  BuildEventType
+	Stage BuildStage
+	// Platform of the stage, the default platform for single platform builds and for the stages not specific to a platform
+	Platform image.Platform
+	Time     time.Time
+	// Duration of the stage, set for completed and failed stages
+	Duration time.Duration
+	// Error of failed stages
+	Error error
+	// Additional information about the stage, like the skip reason
+	Message string
+}
+
+// ChannelEvents returns an OnEvent callback forwarding the events to the channel
+func ChannelEvents(events chan<- BuildEvent) func(BuildEvent) {
+	return func(event BuildEvent) {
+		events <- event
+	}
+}
+
+func (o BuildOptions) outputs() []image.Output {
+	if len(o.Outputs) == 0 {
+		return []image.Output{{Transport: image.RegistryOutput}}
+	}
+	return o.Outputs
+}
+
+// The previous image can be reused only when it's pushed to the registry, since that's the only output kfn can check
+func (o BuildOptions) pushOnly() bool {
+	for _, output := range o.outputs() {
+		if !output.IsRegistry() {
+			return false
+		}
+	}
+	return true
+}
+
+func (o BuildOptions) platforms() []image.Platform {
+	if len(o.Platforms) == 0 {
+		return []image.Platform{{}}
+	}
+	return o.Platforms
+}
+
+// sourceDate returns the timestamp of the reproducible builds, nil when the build is not reproducible
+func (o BuildOptions) sourceDate() *time.Time {
+	if !o.Reproducible {
+		return nil
+	}
+	if o.SourceDate.IsZero() {
+		epoch := time.Unix(0, 0).UTC()
+		return &epoch
+	}
+	sourceDate := o.SourceDate.UTC()
+	return &sourceDate
+}
+
+func (o BuildOptions) sbomFormat() sbom.Format {
+	if o.SBOMFormat == "" {
+		return sbom.CycloneDX
+	}
+	return o.SBOMFormat
+}
+
+// forPlatform returns the options emitting the events of the platform stages
+func (o BuildOptions) forPlatform(platform image.Platform) BuildOptions {
+	if len(o.Platforms) > 1 {
+		o.platform = platform
+	}
+	return o
+}
+
+func (o BuildOptions) emit(event BuildEvent) {
+	event.Platform = o.platform
+	if o.OnEvent != nil {
+		o.OnEvent(event)
+	}
+}
+
+// runStage runs fn emitting the events of the stage. The stage fails without running fn if ctx is already done
+func (o BuildOptions) runStage(ctx context.Context, stage BuildStage, fn func() error) error {
+	start := time.Now()
+	o.emit(BuildEvent{Type: StageStarted, Stage: stage, Time: start})
+
+	err := ctx.Err()
+	if err == nil {
+		err = fn()
+	}
+
+	event := BuildEvent{Type: StageCompleted, Stage: stage, Time: time.Now(), Duration: time.Since(start)}
+	if err != nil {
+		event.Type = StageFailed
+		event.Error = err
+	}
+	o.emit(event)
+
+	return err
+}
+
+func (o BuildOptions) skipStages(message string, stages ...BuildStage) {
+	for _, stage := range stages {
+		o.emit(BuildEvent{Type: StageSkipped, Stage: stage, Time: time.Now(), Message: message})
+	}
+}
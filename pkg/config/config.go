@@ -9,11 +9,16 @@ import (
 	"github.com/containers/image/types"
 	"github.com/mitchellh/go-homedir"
 	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/signature"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -27,19 +32,47 @@ const (
 	DEBUG               = "kfn_debug"
 	CONFIG              = "config"
 	NAMESPACE           = "namespace"
+	RUST_BUILDER_IMAGE  = "rust_builder_image"
+	RUST_HOST_BUILD     = "rust_host_build"
+	REPRODUCIBLE        = "reproducible"
+	SOURCE_DATE_EPOCH   = "source_date_epoch"
+	SBOM_FORMAT         = "sbom_format"
+	SBOM_ATTACH         = "sbom_attach"
+	SIGNING_KEY         = "signing_key"
+	SIGNATURE_POLICY    = "signature_policy"
+	TRUSTED_KEYS        = "trusted_keys"
+	TRUST_SIGNING_KEY   = "trust_signing_key"
+	BASE_IMAGES         = "base_images"
+	BASE_IMAGES_LOCK    = "base_images_lock"
+	OFFLINE             = "offline"
 )
 
+// Image with the rust toolchain and the musl target, used to compile Rust functions
+const DefaultRustBuilderImage = "clux/muslrust:1.38.0-stable"
+
+// Version of kfn, set at build time with -ldflags "-X github.com/slinkydeveloper/kfn/pkg/config.Version=<version>"
+var Version = "dev"
+
 const (
 	kfnDirBase     string = ".kfn"
 	targetDirBase  string = "target"
 	runtimeDirBase string = "runtime"
 	editingDirBase string = "editing"
+	cacheDirBase   string = "cache"
+	signaturesBase string = "signatures"
+	vendorDirBase  string = "vendor"
+	// Base images lock file, in the directory of the config file or in the kfn dir
+	configLockBase string = ".kfn.lock"
+	kfnLockBase    string = "base-images.lock"
 )
 
 var (
 	KfnDir                 string
 	Verbose                bool
 	RuntimeDir             string
+	CacheDir               string
+	SignaturesDir          string
+	VendorDir              string
 	Debug                  bool
 	ImageRegistry          string
 	ImageRegistryUsername  string
@@ -49,6 +82,20 @@ var (
 	Namespace              string
 	BuildahIsolation       buildah.Isolation
 	BuildSystemContext     *types.SystemContext
+	RustBuilderImage       string
+	RustHostBuild          bool
+	Offline                bool
+	Reproducible           bool
+	SourceDate             time.Time
+	SBOMFormat             sbom.Format
+	SBOMAttach             bool
+	SigningKey             string
+	SignaturePolicy        signature.Policy
+	TrustedKeys            []string
+	TrustSigningKey        bool
+	// Base images per language, overriding the default ones
+	BaseImages     map[string]string
+	BaseImagesLock string
 )
 
 func init() {
@@ -97,6 +144,9 @@ func InitKfnDir() {
 	}
 
 	RuntimeDir = path.Join(KfnDir, runtimeDirBase)
+	CacheDir = path.Join(KfnDir, cacheDirBase)
+	SignaturesDir = path.Join(KfnDir, signaturesBase)
+	VendorDir = path.Join(KfnDir, vendorDirBase)
 
 	log.Debugf("Kfn dir: %s", KfnDir)
 }
@@ -109,28 +159,100 @@ func GetEditingDir(functionLocation string) string {
 	return path.Join(KfnDir, getFunctionHash(functionLocation), editingDirBase)
 }
 
-func InitBuildVariables(cmd *cobra.Command) error {
-	ImageRegistry = getEnvOrFail(REGISTRY)
+// InitBuildVariables configures the build. The registry is required only when the image is pushed,
+// otherwise it's used to name the image if available
+func InitBuildVariables(cmd *cobra.Command, requireRegistry bool) error {
+	if requireRegistry {
+		ImageRegistry = getEnvOrFail(REGISTRY)
+	} else {
+		ImageRegistry = getEnvStringOrDefault(REGISTRY, "")
+	}
 	ImageRegistryUsername = getEnvStringOrDefault(REGISTRY_USERNAME, "")
 	ImageRegistryPassword = getEnvStringOrDefault(REGISTRY_PASSWORD, "")
 	ImageRegistryTLSVerify = getEnvBoolOrDefault(REGISTRY_TLS_VERIFY, true)
 
 	var err error
 	BuildSystemContext, err = parseSystemContext(cmd)
-	ImageRegistry, ImageRegistryUsername, ImageRegistryPassword, err = inferImageRegistry(BuildSystemContext)
 	if err != nil {
 		return err
 	}
+
+	registry, username, password, err := inferImageRegistry(BuildSystemContext)
+	if err != nil {
+		if requireRegistry {
+			return err
+		}
+		log.Debugf("No registry configured: %v", err)
+	} else {
+		ImageRegistry, ImageRegistryUsername, ImageRegistryPassword = registry, username, password
+	}
 	setSystemContextCredentials(BuildSystemContext, ImageRegistryUsername, ImageRegistryPassword)
 
 	BuildahIsolation = getBuildahIsolation()
 
+	RustBuilderImage = getEnvStringOrDefault(RUST_BUILDER_IMAGE, DefaultRustBuilderImage)
+	RustHostBuild = getEnvBoolOrDefault(RUST_HOST_BUILD, false)
+	Offline = getEnvBoolOrDefault(OFFLINE, false)
+
+	Reproducible = getEnvBoolOrDefault(REPRODUCIBLE, false)
+	SourceDate, err = getSourceDate()
+	if err != nil {
+		return err
+	}
+
+	SBOMFormat, err = sbom.ParseFormat(getEnvStringOrDefault(SBOM_FORMAT, ""))
+	if err != nil {
+		return err
+	}
+	SBOMAttach = getEnvBoolOrDefault(SBOM_ATTACH, false)
+	SigningKey = getEnvStringOrDefault(SIGNING_KEY, "")
+	InitBaseImagesVariables()
+
 	return nil
 }
 
-func InitRunVariables() {
+// InitBaseImagesVariables reads the base images configured in the config file and the location of their lock file
+func InitBaseImagesVariables() {
+	BaseImages = viper.GetStringMapString(BASE_IMAGES)
+
+	BaseImagesLock = path.Join(KfnDir, kfnLockBase)
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		BaseImagesLock = path.Join(path.Dir(configFile), configLockBase)
+	}
+	BaseImagesLock = getEnvStringOrDefault(BASE_IMAGES_LOCK, BaseImagesLock)
+}
+
+// The timestamp of the reproducible builds is read from SOURCE_DATE_EPOCH, the standard variable
+// containing the seconds since the unix epoch. It's zero when not set
+func getSourceDate() (time.Time, error) {
+	epoch := getEnvStringOrDefault(SOURCE_DATE_EPOCH, "")
+	if epoch == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %s, expected the seconds since the unix epoch", epoch)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+func InitRunVariables() error {
 	Kubeconfig = getEnvStringOrDefault(KUBECONFIG, "")
 	Namespace = getEnvStringOrDefault(NAMESPACE, "default")
+
+	var err error
+	SignaturePolicy, err = signature.ParsePolicy(getEnvStringOrDefault(SIGNATURE_POLICY, ""))
+	if err != nil {
+		return err
+	}
+	TrustedKeys = nil
+	for _, key := range strings.Split(getEnvStringOrDefault(TRUSTED_KEYS, ""), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			TrustedKeys = append(TrustedKeys, key)
+		}
+	}
+	TrustSigningKey = getEnvBoolOrDefault(TRUST_SIGNING_KEY, false)
+	return nil
 }
 
 func getBuildahIsolation() buildah.Isolation {
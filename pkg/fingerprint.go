@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+const (
+	buildRecordFile = "build.json"
+)
+
+// buildRecord is stored in the target directory after every successful build
+type buildRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	Image       string `json:"image"`
+	ImageName   string `json:"imageName"`
+	Tag         string `json:"tag"`
+	Digest      string `json:"digest"`
+	// Base image as configured and pinned to the digest recorded in the base images lock
+	BaseImage       string `json:"baseImage,omitempty"`
+	PinnedBaseImage string `json:"pinnedBaseImage,omitempty"`
+}
+
+// buildHashes are the hashes of the build inputs
+type buildHashes struct {
+	// Fingerprint of the whole build, used to skip the builds when nothing changed
+	fingerprint string
+	source      string
+	runtime     string
+}
+
+// computeFingerprint hashes everything that affects the built image: the function sources,
+// the effective configuration, the language runtime, the base image, the platforms and the reproducibility
+func computeFingerprint(ctx context.Context, fn preparedFunction, options BuildOptions) (buildHashes, error) {
+	function, functionConfiguration := fn.function, fn.configuration
+	runtimeFingerprint, err := fn.languageManager.RuntimeFingerprint(ctx, functionConfiguration)
+	if err != nil {
+		return buildHashes{}, err
+	}
+
+	sourceFingerprint := util.NewFingerprint()
+	if function.IsDirectory() {
+		ignore, err := util.LoadIgnoreFile(function.Directory)
+		if err != nil {
+			return buildHashes{}, err
+		}
+		sourceFingerprint.AddString(function.Entry())
+		err = sourceFingerprint.AddTree(function.Directory, ignore)
+	} else {
+		err = sourceFingerprint.AddFile(function.MainFile)
+	}
+	if err != nil {
+		return buildHashes{}, err
+	}
+	sourceHash := sourceFingerprint.Sum()
+
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddString(string(fn.language))
+	for _, platform := range options.Platforms {
+		fingerprint.AddString(platform.String())
+	}
+	if sourceDate := options.sourceDate(); sourceDate != nil {
+		fingerprint.AddString("reproducible", sourceDate.Format(time.RFC3339))
+	}
+	fingerprint.AddString(runtimeFingerprint)
+	if baseImage := fn.configuredBaseImage(options); baseImage != "" {
+		fingerprint.AddString("base-image", baseImage)
+	}
+	for _, k := range functionConfiguration.Keys() {
+		fingerprint.AddString(k)
+		fingerprint.AddString(functionConfiguration.Strings(k)...)
+	}
+	fingerprint.AddString(sourceHash)
+
+	return buildHashes{fingerprint: fingerprint.Sum(), source: sourceHash, runtime: runtimeFingerprint}, nil
+}
+
+// reusableImage returns the image built previously if the fingerprint matches, its base image is still pinned to the same digest
+// and the image is still in the registry
+func reusableImage(ctx context.Context, targetDir string, fingerprint string, options BuildOptions) (image.FunctionImage, bool) {
+	recordBytes, err := ioutil.ReadFile(path.Join(targetDir, buildRecordFile))
+	if err != nil {
+		return image.FunctionImage{}, false
+	}
+
+	var record buildRecord
+	if err := json.Unmarshal(recordBytes, &record); err != nil {
+		log.Debugf("Ignoring invalid build record: %v", err)
+		return image.FunctionImage{}, false
+	}
+
+	img := image.FunctionImage{ImageName: options.ImageName, Tag: options.ImageTag, Digest: record.Digest}
+	if record.Fingerprint != fingerprint || record.Image != img.FullName() || record.Digest == "" {
+		return image.FunctionImage{}, false
+	}
+
+	// The base images that couldn't be pinned are not checked
+	if options.BaseImagesLock != "" && record.PinnedBaseImage != record.BaseImage {
+		lock, err := util.LoadBaseImagesLock(options.BaseImagesLock)
+		if err != nil {
+			log.Debugf("Ignoring invalid base images lock: %v", err)
+			return image.FunctionImage{}, false
+		}
+		if pinned, ok := lock.Pinned(record.BaseImage); !ok || pinned != record.PinnedBaseImage {
+			log.Infof("Base image %s changed, rebuilding", record.BaseImage)
+			return image.FunctionImage{}, false
+		}
+	}
+
+	remoteDigest, err := img.RemoteDigest(ctx, options.SystemContext)
+	if err != nil {
+		log.Infof("Cannot check the previously built image %s, rebuilding: %v", img.FullName(), err)
+		return image.FunctionImage{}, false
+	}
+	if remoteDigest != record.Digest {
+		log.Infof("Image %s changed in the registry, rebuilding", img.FullName())
+		return image.FunctionImage{}, false
+	}
+
+	return img, true
+}
+
+func saveBuildRecord(targetDir string, fingerprint string, img image.FunctionImage, base pinnedBaseImage) error {
+	recordBytes, err := json.MarshalIndent(buildRecord{
+		Fingerprint:     fingerprint,
+		Image:           img.FullName(),
+		ImageName:       img.ImageName,
+		Tag:             img.Tag,
+		Digest:          img.Digest,
+		BaseImage:       base.name,
+		PinnedBaseImage: base.pinned,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(targetDir, util.WriteDest{Filename: buildRecordFile, Data: recordBytes})
+}
@@ -0,0 +1,132 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/containers/image/pkg/blobinfocache/none"
+	"github.com/containers/image/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// Attachments are OCI artifacts stored in the image repository and tagged after the digest of the image,
+// like sha256-<hex>.sbom, so they can be found from the image digest
+type attachmentManifest struct {
+	SchemaVersion int                    `json:"schemaVersion"`
+	MediaType     string                 `json:"mediaType"`
+	Config        imgspecv1.Descriptor   `json:"config"`
+	Layers        []imgspecv1.Descriptor `json:"layers"`
+}
+
+// AttachmentTag returns the tag of the attachment of the pushed image with the suffix
+func (image FunctionImage) AttachmentTag(suffix string) (string, error) {
+	d, err := digest.Parse(image.Digest)
+	if err != nil {
+		return "", fmt.Errorf("image %s is not pushed: %v", image.FullName(), err)
+	}
+	return strings.Replace(d.String(), ":", "-", 1) + "." + suffix, nil
+}
+
+// PushAttachment pushes data, with the provided media type, as an attachment of the pushed image.
+// It returns the attachment image
+func (image FunctionImage) PushAttachment(ctx context.Context, systemContext *types.SystemContext, suffix string, mediaType string, fileName string, data []byte) (FunctionImage, error) {
+	tag, err := image.AttachmentTag(suffix)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	attachment := FunctionImage{ImageName: image.ImageName, Tag: tag}
+
+	ref, err := attachment.ParseSpecDest()
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	dest, err := ref.NewImageDestination(ctx, systemContext)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	defer dest.Close()
+
+	// The registries require a config blob, so the artifact has an empty one
+	config := []byte("{}")
+	configInfo, err := dest.PutBlob(ctx, bytes.NewReader(config), types.BlobInfo{Digest: digest.FromBytes(config), Size: int64(len(config))}, none.NoCache, true)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	layerInfo, err := dest.PutBlob(ctx, bytes.NewReader(data), types.BlobInfo{Digest: digest.FromBytes(data), Size: int64(len(data))}, none.NoCache, false)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+
+	rawManifest, err := json.Marshal(attachmentManifest{
+		SchemaVersion: 2,
+		MediaType:     imgspecv1.MediaTypeImageManifest,
+		Config:        imgspecv1.Descriptor{MediaType: imgspecv1.MediaTypeImageConfig, Digest: configInfo.Digest, Size: configInfo.Size},
+		Layers: []imgspecv1.Descriptor{{
+			MediaType:   mediaType,
+			Digest:      layerInfo.Digest,
+			Size:        layerInfo.Size,
+			Annotations: map[string]string{imgspecv1.AnnotationTitle: fileName},
+		}},
+	})
+	if err != nil {
+		return FunctionImage{}, err
+	}
+
+	if err := dest.PutManifest(ctx, rawManifest); err != nil {
+		return FunctionImage{}, fmt.Errorf("error while pushing the attachment %s: %v", attachment.FullName(), err)
+	}
+	if err := dest.Commit(ctx); err != nil {
+		return FunctionImage{}, err
+	}
+
+	attachment.Digest = digest.FromBytes(rawManifest).String()
+	return attachment, nil
+}
+
+// FetchAttachment reads the data of the attachment of the pushed image with the suffix.
+// It returns nil when the image has no such attachment
+func (image FunctionImage) FetchAttachment(ctx context.Context, systemContext *types.SystemContext, suffix string) ([]byte, error) {
+	tag, err := image.AttachmentTag(suffix)
+	if err != nil {
+		return nil, err
+	}
+	attachment := FunctionImage{ImageName: image.ImageName, Tag: tag}
+
+	ref, err := attachment.ParseSpecDest()
+	if err != nil {
+		return nil, err
+	}
+	src, err := ref.NewImageSource(ctx, systemContext)
+	if err != nil {
+		// The registries answer with different errors for the missing tags, so any error means no attachment
+		log.Debugf("Cannot read the attachment %s: %v", attachment.FullName(), err)
+		return nil, nil
+	}
+	defer src.Close()
+
+	rawManifest, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		log.Debugf("Cannot read the attachment %s: %v", attachment.FullName(), err)
+		return nil, nil
+	}
+	var m attachmentManifest
+	if err := json.Unmarshal(rawManifest, &m); err != nil {
+		return nil, fmt.Errorf("invalid attachment %s: %v", attachment.FullName(), err)
+	}
+	if len(m.Layers) != 1 {
+		return nil, fmt.Errorf("invalid attachment %s: expected one layer, found %d", attachment.FullName(), len(m.Layers))
+	}
+
+	blob, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: m.Layers[0].Digest, Size: m.Layers[0].Size}, none.NoCache)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return ioutil.ReadAll(blob)
+}
@@ -1,7 +1,9 @@
 package image
 
 import (
+	"context"
 	"fmt"
+	"github.com/containers/image/manifest"
 	"github.com/containers/image/transports"
 	"github.com/containers/image/transports/alltransports"
 	"github.com/containers/image/types"
@@ -12,6 +14,10 @@ import (
 type FunctionImage struct {
 	ImageName string
 	Tag       string
+	// ID of the image in the local containers storage
+	ID string
+	// Digest of the pushed manifest
+	Digest string
 }
 
 func (image FunctionImage) ParseSpecDest() (types.ImageReference, error) {
@@ -38,12 +44,66 @@ func (image FunctionImage) ParseSpecDest() (types.ImageReference, error) {
 	return dest, nil
 }
 
+// RemoteDigest returns the digest of the manifest currently pushed in the registry
+func (image FunctionImage) RemoteDigest(ctx context.Context, systemContext *types.SystemContext) (string, error) {
+	rawManifest, _, err := image.RemoteManifest(ctx, systemContext)
+	if err != nil {
+		return "", err
+	}
+
+	d, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// RemoteManifest returns the manifest currently pushed in the registry together with its MIME type
+func (image FunctionImage) RemoteManifest(ctx context.Context, systemContext *types.SystemContext) ([]byte, string, error) {
+	ref, err := image.ParseSpecDest()
+	if err != nil {
+		return nil, "", err
+	}
+
+	src, err := ref.NewImageSource(ctx, systemContext)
+	if err != nil {
+		return nil, "", err
+	}
+	defer src.Close()
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(rawManifest)
+	}
+	return rawManifest, mimeType, nil
+}
+
+// Images built without a registry are named like buildah does for local images
+const localRegistry = "localhost"
+
 func (image FunctionImage) FullName() string {
+	registry := config.ImageRegistry
+	if registry == "" {
+		registry = localRegistry
+	}
+
 	if image.Tag != "" {
-		return fmt.Sprintf("%s/%s:%s", config.ImageRegistry, image.ImageName, image.Tag)
+		return fmt.Sprintf("%s/%s:%s", registry, image.ImageName, image.Tag)
 	} else {
-		return fmt.Sprintf("%s/%s", config.ImageRegistry, image.ImageName)
+		return fmt.Sprintf("%s/%s", registry, image.ImageName)
+	}
+}
+
+// PinnedNameForK8s returns the repository of the image with its digest instead of the tag, so the deployed image doesn't change
+// when the tag is pushed again. Without digest it's the tagged name
+func (image FunctionImage) PinnedNameForK8s() string {
+	if image.Digest == "" {
+		return image.FullNameForK8s()
 	}
+	return FunctionImage{ImageName: image.ImageName}.FullNameForK8s() + "@" + image.Digest
 }
 
 func (image FunctionImage) FullNameForK8s() string {
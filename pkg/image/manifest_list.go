@@ -0,0 +1,92 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/types"
+)
+
+// PlatformImage is the image built for a platform of a multi-architecture build
+type PlatformImage struct {
+	Platform Platform
+	Image    FunctionImage
+}
+
+type manifestListEntry struct {
+	MediaType string               `json:"mediaType"`
+	Size      int                  `json:"size"`
+	Digest    string               `json:"digest"`
+	Platform  manifestListPlatform `json:"platform"`
+}
+
+type manifestListPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type manifestList struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	MediaType     string              `json:"mediaType"`
+	Manifests     []manifestListEntry `json:"manifests"`
+}
+
+// PushManifestList pushes to the image tag a manifest list referencing the platform images,
+// which must be already pushed in the same repository. It returns the image with the digest of the list
+func (image FunctionImage) PushManifestList(ctx context.Context, systemContext *types.SystemContext, images []PlatformImage) (FunctionImage, error) {
+	list := manifestList{SchemaVersion: 2, MediaType: manifest.DockerV2ListMediaType}
+	for _, platformImage := range images {
+		rawManifest, mimeType, err := platformImage.Image.RemoteManifest(ctx, systemContext)
+		if err != nil {
+			return FunctionImage{}, err
+		}
+		d, err := manifest.Digest(rawManifest)
+		if err != nil {
+			return FunctionImage{}, err
+		}
+
+		list.Manifests = append(list.Manifests, manifestListEntry{
+			MediaType: mimeType,
+			Size:      len(rawManifest),
+			Digest:    d.String(),
+			Platform: manifestListPlatform{
+				Architecture: platformImage.Platform.ArchitectureOrDefault(),
+				OS:           platformImage.Platform.OSOrDefault(),
+				Variant:      platformImage.Platform.Variant,
+			},
+		})
+	}
+
+	rawList, err := json.Marshal(list)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+
+	ref, err := image.ParseSpecDest()
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	dest, err := ref.NewImageDestination(ctx, systemContext)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	defer dest.Close()
+
+	if err := dest.PutManifest(ctx, rawList); err != nil {
+		return FunctionImage{}, fmt.Errorf("error while pushing the manifest list %s: %v", image.FullName(), err)
+	}
+	if err := dest.Commit(ctx); err != nil {
+		return FunctionImage{}, err
+	}
+
+	d, err := manifest.Digest(rawList)
+	if err != nil {
+		return FunctionImage{}, err
+	}
+	image.ID = ""
+	image.Digest = d.String()
+	return image, nil
+}
@@ -0,0 +1,106 @@
+package image
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Labels stamped on the function images. The images use the Docker v2 manifest, which has no annotations,
+// so the standard OCI annotation keys are stored as labels of the image configuration
+const (
+	CreatedLabel      = "org.opencontainers.image.created"
+	TitleLabel        = "org.opencontainers.image.title"
+	SourceLabel       = "org.opencontainers.image.source"
+	RevisionLabel     = "org.opencontainers.image.revision"
+	LanguageLabel     = "kfn.language"
+	FunctionLabel     = "kfn.function"
+	SourceHashLabel   = "kfn.source-hash"
+	RuntimeHashLabel  = "kfn.runtime-hash"
+	BuildHashLabel    = "kfn.build-hash"
+	DependenciesLabel = "kfn.dependencies"
+	VersionLabel      = "kfn.version"
+	// Base image of the function image, with its digest. It's set when building the image, since the base image is resolved by the build
+	BaseImageLabel = "kfn.base-image"
+)
+
+// Metadata describes how a function image was produced
+type Metadata struct {
+	Function string `json:"function,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Location of the function sources: the remote URL or the git remote of the function directory
+	Source string `json:"source,omitempty"`
+	// Git commit of the function sources
+	Revision string `json:"revision,omitempty"`
+	// Hash of the function sources
+	SourceHash string `json:"sourceHash,omitempty"`
+	// Hash of the language runtime, including the base and builder images
+	RuntimeHash string `json:"runtimeHash,omitempty"`
+	// Hash of all the build inputs, including the configuration and the platforms
+	BuildHash string `json:"buildHash,omitempty"`
+	// Declared dependencies, from name to version
+	Dependencies map[string]string `json:"dependencies,omitempty"`
+	// Base image with its digest, read only from the image labels
+	BaseImage  string    `json:"baseImage,omitempty"`
+	KfnVersion string    `json:"kfnVersion,omitempty"`
+	Created    time.Time `json:"created,omitempty"`
+}
+
+// Labels returns the labels of the metadata, omitting the empty fields
+func (m Metadata) Labels() map[string]string {
+	labels := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			labels[key] = value
+		}
+	}
+
+	set(TitleLabel, m.Function)
+	set(FunctionLabel, m.Function)
+	set(LanguageLabel, m.Language)
+	set(SourceLabel, m.Source)
+	set(RevisionLabel, m.Revision)
+	set(SourceHashLabel, m.SourceHash)
+	set(RuntimeHashLabel, m.RuntimeHash)
+	set(BuildHashLabel, m.BuildHash)
+	set(VersionLabel, m.KfnVersion)
+	if !m.Created.IsZero() {
+		set(CreatedLabel, m.Created.UTC().Format(time.RFC3339))
+	}
+	if len(m.Dependencies) != 0 {
+		// Versions can contain any character, like the python specifiers >=1.0,<2, so dependencies are encoded in json
+		deps, _ := json.Marshal(m.Dependencies)
+		set(DependenciesLabel, string(deps))
+	}
+	return labels
+}
+
+// MetadataFromLabels reads back the metadata stamped by kfn, ignoring the invalid values
+func MetadataFromLabels(labels map[string]string) Metadata {
+	m := Metadata{
+		Function:    labels[FunctionLabel],
+		Language:    labels[LanguageLabel],
+		Source:      labels[SourceLabel],
+		Revision:    labels[RevisionLabel],
+		SourceHash:  labels[SourceHashLabel],
+		RuntimeHash: labels[RuntimeHashLabel],
+		BuildHash:   labels[BuildHashLabel],
+		KfnVersion:  labels[VersionLabel],
+		BaseImage:   labels[BaseImageLabel],
+	}
+	if m.Function == "" {
+		m.Function = labels[TitleLabel]
+	}
+	if created, err := time.Parse(time.RFC3339, labels[CreatedLabel]); err == nil {
+		m.Created = created
+	}
+	if deps, ok := labels[DependenciesLabel]; ok {
+		_ = json.Unmarshal([]byte(deps), &m.Dependencies)
+	}
+	return m
+}
+
+// IsFunctionImage returns true if the labels were stamped by kfn
+func IsFunctionImage(labels map[string]string) bool {
+	_, ok := labels[FunctionLabel]
+	return ok
+}
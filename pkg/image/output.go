@@ -0,0 +1,102 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+)
+
+const (
+	// RegistryOutput pushes the image to the configured registry
+	RegistryOutput = "registry"
+	// ContainersStorageOutput keeps the image only in the local containers storage, where it's committed by the build
+	ContainersStorageOutput = "containers-storage"
+	OCIArchiveOutput        = "oci-archive"
+	DockerArchiveOutput     = "docker-archive"
+	OCILayoutOutput         = "oci"
+)
+
+// Output is a destination of the built image, in the form <transport>[:<path>[:<reference>]]
+type Output struct {
+	Transport string
+	// Destination path and optional reference, for archive and layout outputs
+	Path string
+}
+
+func (o Output) String() string {
+	if o.Path == "" {
+		return o.Transport
+	}
+	return o.Transport + ":" + o.Path
+}
+
+func (o Output) IsRegistry() bool {
+	return o.Transport == RegistryOutput
+}
+
+func (o Output) IsLocalStorage() bool {
+	return o.Transport == ContainersStorageOutput
+}
+
+func ParseOutput(spec string) (Output, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	output := Output{Transport: parts[0]}
+	if len(parts) == 2 {
+		output.Path = parts[1]
+	}
+
+	switch output.Transport {
+	case RegistryOutput, ContainersStorageOutput:
+		if output.Path != "" {
+			return Output{}, fmt.Errorf("output %s doesn't accept a path", output.Transport)
+		}
+	case OCIArchiveOutput, DockerArchiveOutput, OCILayoutOutput:
+		if output.Path == "" {
+			return Output{}, fmt.Errorf("output %s requires a path, like %s:/tmp/function", output.Transport, output.Transport)
+		}
+	default:
+		return Output{}, fmt.Errorf("unknown output %s, allowed outputs are %s, %s, %s:<path>, %s:<path> and %s:<path>",
+			spec, RegistryOutput, ContainersStorageOutput, OCIArchiveOutput, DockerArchiveOutput, OCILayoutOutput)
+	}
+
+	return output, nil
+}
+
+// ParseOutputs parses the outputs, returning the registry output when none is provided
+func ParseOutputs(specs []string) ([]Output, error) {
+	if len(specs) == 0 {
+		return []Output{{Transport: RegistryOutput}}, nil
+	}
+
+	outputs := make([]Output, 0, len(specs))
+	for _, spec := range specs {
+		output, err := ParseOutput(spec)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// RequiresRegistry returns true if one of the outputs pushes the image
+func RequiresRegistry(outputs []Output) bool {
+	for _, o := range outputs {
+		if o.IsRegistry() {
+			return true
+		}
+	}
+	return false
+}
+
+// Reference returns the destination of the image for archive and layout outputs.
+// Docker archives without a reference are tagged with the image name, so docker load can name the loaded image
+func (o Output) Reference(image FunctionImage) (types.ImageReference, error) {
+	spec := o.String()
+	if o.Transport == DockerArchiveOutput && !strings.Contains(o.Path, ":") {
+		spec = spec + ":" + image.FullNameForK8s()
+	}
+	return alltransports.ParseImageName(spec)
+}
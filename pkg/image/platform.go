@@ -0,0 +1,78 @@
+package image
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Platform of an image, like linux/arm64. The zero value is the default platform of kfn, linux/amd64,
+// built without recording the platform choice
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// ParsePlatform parses platforms in the form os/arch[/variant]
+func ParsePlatform(value string) (Platform, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("invalid platform %s, expected os/arch[/variant], like linux/arm64", value)
+	}
+
+	platform := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+func ParsePlatforms(values []string) ([]Platform, error) {
+	platforms := make([]Platform, 0, len(values))
+	for _, v := range values {
+		platform, err := ParsePlatform(v)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, platform)
+	}
+	return platforms, nil
+}
+
+func (p Platform) IsDefault() bool {
+	return p == Platform{}
+}
+
+// OSOrDefault and ArchitectureOrDefault resolve the default platform
+func (p Platform) OSOrDefault() string {
+	if p.OS == "" {
+		return "linux"
+	}
+	return p.OS
+}
+
+func (p Platform) ArchitectureOrDefault() string {
+	if p.Architecture == "" {
+		return "amd64"
+	}
+	return p.Architecture
+}
+
+func (p Platform) String() string {
+	if p.IsDefault() {
+		return "default"
+	}
+	if p.Variant != "" {
+		return p.OS + "/" + p.Architecture + "/" + p.Variant
+	}
+	return p.OS + "/" + p.Architecture
+}
+
+// Tag returns the tag of the platform image, pushed before assembling the manifest list
+func (p Platform) Tag(tag string) string {
+	suffix := strings.ReplaceAll(p.String(), "/", "-")
+	if tag == "" {
+		return suffix
+	}
+	return tag + "-" + suffix
+}
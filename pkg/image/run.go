@@ -1,22 +1,79 @@
 package image
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	serving_v1alpha1_api "knative.dev/serving/pkg/apis/serving/v1alpha1"
 	servingv1alpha1 "knative.dev/serving/pkg/client/clientset/versioned/typed/serving/v1alpha1"
 )
 
-func (image FunctionImage) RunImage(client servingv1alpha1.ServingV1alpha1Interface, serviceName string, namespace string) error {
-	service := image.constructService(serviceName, namespace)
+const (
+	minScaleAnnotation = "autoscaling.knative.dev/minScale"
+	maxScaleAnnotation = "autoscaling.knative.dev/maxScale"
+)
+
+// Knative eventing isn't a dependency of kfn, so the triggers are created with the dynamic client
+var triggerResource = schema.GroupVersionResource{Group: "eventing.knative.dev", Version: "v1alpha1", Resource: "triggers"}
+
+// ServiceOptions configures the deployed service. Zero values are not applied
+type ServiceOptions struct {
+	// Environment variables in the form NAME=VALUE
+	Env      []string
+	MinScale int
+	MaxScale int
+	Triggers []Trigger
+}
+
+// Trigger subscribes the service to the events of the broker matching all the attributes
+type Trigger struct {
+	Broker     string
+	Attributes map[string]string
+}
+
+// RunImage creates the service, deploying the image by digest when it's known, and its triggers, owned by the service so they are
+// deleted together with it. The clients don't support cancellation, so when ctx is done RunImage returns without waiting the response of the api server
+func (image FunctionImage) RunImage(ctx context.Context, client servingv1alpha1.ServingV1alpha1Interface, eventingClient dynamic.Interface, serviceName string, namespace string, options ServiceOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	_, err := client.Services(namespace).Create(&service)
+	service := image.constructService(serviceName, namespace, options)
 
-	return err
+	result := make(chan error, 1)
+	go func() {
+		created, err := client.Services(namespace).Create(&service)
+		if err != nil {
+			result <- err
+			return
+		}
+		for i, trigger := range options.Triggers {
+			t := constructTrigger(created, i, trigger)
+			if _, err := eventingClient.Resource(triggerResource).Namespace(namespace).Create(t, metav1.CreateOptions{}); err != nil {
+				result <- fmt.Errorf("cannot create the trigger %s: %v", t.GetName(), err)
+				return
+			}
+		}
+		result <- nil
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Create service struct from provided options
-func (image FunctionImage) constructService(name string, namespace string) serving_v1alpha1_api.Service {
+func (image FunctionImage) constructService(name string, namespace string, options ServiceOptions) serving_v1alpha1_api.Service {
 	service := serving_v1alpha1_api.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      name,
@@ -27,9 +84,63 @@ func (image FunctionImage) constructService(name string, namespace string) servi
 	service.Spec.Template = &serving_v1alpha1_api.RevisionTemplateSpec{
 		Spec: serving_v1alpha1_api.RevisionSpec{},
 	}
+
+	annotations := make(map[string]string)
+	if options.MinScale != 0 {
+		annotations[minScaleAnnotation] = strconv.Itoa(options.MinScale)
+	}
+	if options.MaxScale != 0 {
+		annotations[maxScaleAnnotation] = strconv.Itoa(options.MaxScale)
+	}
+	if len(annotations) != 0 {
+		service.Spec.Template.Annotations = annotations
+	}
+
+	env := make([]corev1.EnvVar, 0, len(options.Env))
+	for _, e := range options.Env {
+		kv := strings.SplitN(e, "=", 2)
+		env = append(env, corev1.EnvVar{Name: kv[0], Value: kv[1]})
+	}
+
 	service.Spec.Template.Spec.Containers = []corev1.Container{{
-		Image: image.FullNameForK8s(),
+		Image: image.PinnedNameForK8s(),
+		Env:   env,
 	}}
 
 	return service
 }
+
+// Create the trigger subscribing the service, named after the service and the trigger position
+func constructTrigger(service *serving_v1alpha1_api.Service, index int, trigger Trigger) *unstructured.Unstructured {
+	attributes := make(map[string]interface{}, len(trigger.Attributes))
+	for k, v := range trigger.Attributes {
+		attributes[k] = v
+	}
+
+	t := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"broker": trigger.Broker,
+			"filter": map[string]interface{}{
+				"attributes": attributes,
+			},
+			"subscriber": map[string]interface{}{
+				"ref": map[string]interface{}{
+					"apiVersion": serving_v1alpha1_api.SchemeGroupVersion.String(),
+					"kind":       "Service",
+					"name":       service.Name,
+				},
+			},
+		},
+	}}
+	t.SetAPIVersion(triggerResource.GroupVersion().String())
+	t.SetKind("Trigger")
+	t.SetName(fmt.Sprintf("%s-trigger-%d", service.Name, index+1))
+	t.SetNamespace(service.Namespace)
+	t.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: serving_v1alpha1_api.SchemeGroupVersion.String(),
+		Kind:       "Service",
+		Name:       service.Name,
+		UID:        service.UID,
+	}})
+	return t
+}
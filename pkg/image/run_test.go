@@ -0,0 +1,43 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	serving_v1alpha1_api "knative.dev/serving/pkg/apis/serving/v1alpha1"
+)
+
+func TestConstructServiceDeploysDigest(t *testing.T) {
+	config.ImageRegistry = "docker.io/user"
+	defer func() { config.ImageRegistry = "" }()
+
+	functionImage := FunctionImage{ImageName: "function", Tag: "latest", Digest: "sha256:0123"}
+	service := functionImage.constructService("function", "default", ServiceOptions{})
+
+	if image := service.Spec.Template.Spec.Containers[0].Image; image != "docker.io/user/function@sha256:0123" {
+		t.Errorf("expected the image pinned to the digest, got %s", image)
+	}
+}
+
+func TestConstructTriggerSubscribesService(t *testing.T) {
+	service := &serving_v1alpha1_api.Service{ObjectMeta: metav1.ObjectMeta{Name: "function", Namespace: "default", UID: "1234"}}
+	trigger := constructTrigger(service, 1, Trigger{Broker: "orders", Attributes: map[string]string{"type": "dev.knative.example"}})
+
+	if name := trigger.GetName(); name != "function-trigger-2" {
+		t.Errorf("expected the trigger named after the service, got %s", name)
+	}
+	if broker, _, _ := unstructured.NestedString(trigger.Object, "spec", "broker"); broker != "orders" {
+		t.Errorf("expected the orders broker, got %s", broker)
+	}
+	if eventType, _, _ := unstructured.NestedString(trigger.Object, "spec", "filter", "attributes", "type"); eventType != "dev.knative.example" {
+		t.Errorf("expected the type filter, got %s", eventType)
+	}
+	if subscriber, _, _ := unstructured.NestedString(trigger.Object, "spec", "subscriber", "ref", "name"); subscriber != "function" {
+		t.Errorf("expected the service as subscriber, got %s", subscriber)
+	}
+	if owners := trigger.GetOwnerReferences(); len(owners) != 1 || owners[0].UID != "1234" {
+		t.Errorf("expected the service as owner, got %v", owners)
+	}
+}
@@ -1,8 +1,8 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
-	"github.com/containers/image/types"
 	log "github.com/sirupsen/logrus"
 	"github.com/slinkydeveloper/kfn/pkg/config"
 	"github.com/slinkydeveloper/kfn/pkg/image"
@@ -10,8 +10,11 @@ import (
 	"github.com/slinkydeveloper/kfn/pkg/util"
 	"io/ioutil"
 	"k8s.io/apimachinery/pkg/util/rand"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,101 +24,369 @@ func init() {
 
 // Build function handles the different build steps:
 // 1. Calculate and create target dir and runtime dir if not existing
-// 2. Resolve the runtime manager
-// 3. Download required runtime files if needed
-// 4. Resolve the compiler manager
-// 5. Check if compile dependencies are available (compiler, libraries, etc)
-// 6. Download on the local filesystem the function if remote
-// 7. Run compilation and output the files to move on the image
-// 8. Resolve image builder
-// 9. Build image and return the image id
-func Build(location string, language languages.Language, imageName string, imageTag string, systemContext *types.SystemContext) (image.FunctionImage, error) {
-	targetDir := config.GetTargetDir(location)
+// 2. Download on the local filesystem the function if remote
+// 3. Resolve the language manager
+// 4. Download required runtime files if needed
+// 5. Compute the build fingerprint and reuse the previous image if nothing changed, unless forced
+// 6. Check if compile dependencies are available (compiler, libraries, etc)
+// 7. Configure the target directory
+// 8. Run compilation and output the files to move on the image
+// 9. Build the image labeled with the function metadata, push it to the registry and export it to the other outputs
+// 10. Write the SBOM of the image in the target directory
+// 11. Sign the digest of the pushed image
+// Every step emits its progress through options.OnEvent.
+// When ctx is cancelled or its deadline expires, the running step is interrupted and Build returns the ctx error
+// Different functions can be built concurrently, the builds of the same language wait for each other only to download the runtime
+func Build(ctx context.Context, options BuildOptions) (BuildResult, error) {
+	platforms := options.platforms()
+	outputs := options.outputs()
 
-	err := util.MkdirpIfNotExists(targetDir)
+	var exports []image.Output
+	for _, output := range outputs {
+		if !output.IsRegistry() && !output.IsLocalStorage() {
+			exports = append(exports, output)
+		}
+	}
+	if len(platforms) > 1 && len(exports) != 0 {
+		return BuildResult{}, fmt.Errorf("output %s doesn't support multiple platforms", exports[0])
+	}
+
+	var hashes buildHashes
+	fn, err := prepare(ctx, options, func(fn preparedFunction) error {
+		var err error
+		hashes, err = computeFingerprint(ctx, fn, options)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("Build fingerprint: %s", hashes.fingerprint)
+		return nil
+	})
 	if err != nil {
-		return image.FunctionImage{}, err
+		return BuildResult{}, err
 	}
 
-	err = util.MkdirpIfNotExists(config.RuntimeDir)
+	if !options.Force && options.pushOnly() {
+		if functionImage, ok := reusableImage(ctx, fn.targetDir, hashes.fingerprint, options); ok {
+			message := fmt.Sprintf("function unchanged, reusing image %s@%s", functionImage.FullName(), functionImage.Digest)
+			log.Info(message)
+			options.skipStages(message, StageDependencyCheck, StageConfigure, StageCompile, StageImageBuild, StagePush, StageSBOM, StageManifestList)
+			// The image is signed again, in case the signing key changed since the previous build
+			if err := signImage(ctx, options, functionImage); err != nil {
+				return BuildResult{}, err
+			}
+			return BuildResult{Image: functionImage, Configuration: fn.configuration, Skipped: true}, nil
+		}
+	}
+
+	labels := fn.metadata(ctx, options, hashes).Labels()
+
+	// With multiple platforms, every platform image gets its own tag and the requested tag becomes a manifest list
+	var platformImages []image.PlatformImage
+	var base pinnedBaseImage
+	for _, platform := range platforms {
+		platformOptions := options.forPlatform(platform)
+		imageTag := options.ImageTag
+		if len(platforms) > 1 {
+			imageTag = platform.Tag(options.ImageTag)
+		}
+
+		functionImage, platformBase, err := buildPlatformImage(ctx, platformOptions, fn, platform, imageTag, labels)
+		if err != nil {
+			return BuildResult{}, err
+		}
+		platformImages = append(platformImages, image.PlatformImage{Platform: platform, Image: functionImage})
+		// The base images of the platforms are the same manifest list
+		base = platformBase
+	}
+
+	functionImage := platformImages[0].Image
+	if image.RequiresRegistry(outputs) {
+		if len(platformImages) > 1 {
+			err = options.runStage(ctx, StageManifestList, func() error {
+				listImage := image.FunctionImage{ImageName: options.ImageName, Tag: options.ImageTag}
+				log.Infof("Pushing manifest list %s", listImage.FullName())
+				functionImage, err = listImage.PushManifestList(ctx, options.SystemContext, platformImages)
+				return err
+			})
+			if err != nil {
+				return BuildResult{}, err
+			}
+		}
+
+		if err := signImage(ctx, options, functionImage); err != nil {
+			return BuildResult{}, err
+		}
+
+		if err := saveBuildRecord(fn.targetDir, hashes.fingerprint, functionImage, base); err != nil {
+			log.Warnf("Cannot save the build record, the next build won't be skipped: %v", err)
+		}
+	}
+
+	if len(exports) != 0 {
+		err = options.runStage(ctx, StageExport, func() error {
+			for _, output := range exports {
+				log.Infof("Exporting image %s to %s", functionImage.FullName(), output)
+				if err := util.ExportImage(ctx, options.SystemContext, functionImage, output); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return BuildResult{}, err
+		}
+	}
+
+	result := BuildResult{Image: functionImage, Configuration: fn.configuration}
+	if len(platformImages) > 1 {
+		result.PlatformImages = platformImages
+	}
+	return result, nil
+}
+
+// buildPlatformImage compiles the function and builds its image for the platform, pushing it if required.
+// It returns the image together with its base image
+func buildPlatformImage(ctx context.Context, options BuildOptions, fn preparedFunction, platform image.Platform, imageTag string, labels map[string]string) (image.FunctionImage, pinnedBaseImage, error) {
+	recipe, err := fn.compile(ctx, options, platform)
+	if err != nil {
+		return image.FunctionImage{}, pinnedBaseImage{}, err
+	}
+	recipe, base, err := fn.withBaseImage(ctx, options, recipe)
 	if err != nil {
-		return image.FunctionImage{}, err
+		return image.FunctionImage{}, pinnedBaseImage{}, err
 	}
+	recipe = withLabels(recipe, labels)
 
-	languageManager := languages.ResolveLanguageManager(language)
+	var functionImage image.FunctionImage
+	err = options.runStage(ctx, StageImageBuild, func() error {
+		if platform.IsDefault() {
+			log.Info("Starting build image")
+		} else {
+			log.Infof("Starting build image for platform %s", platform)
+		}
+		recipeOptions := util.RecipeBuildOptions{Platform: platform, SourceDate: options.sourceDate(), NoCache: options.noCache}
+		functionImage, err = util.BuildRecipe(ctx, options.SystemContext, recipe, recipeOptions, options.ImageName, imageTag)
+		return err
+	})
+	if err != nil {
+		return image.FunctionImage{}, pinnedBaseImage{}, err
+	}
+
+	if image.RequiresRegistry(options.outputs()) {
+		err = options.runStage(ctx, StagePush, func() error {
+			log.Infof("Pushing image %s", functionImage.FullName())
+			functionImage, err = util.PushImage(ctx, options.SystemContext, functionImage)
+			return err
+		})
+		if err != nil {
+			return image.FunctionImage{}, pinnedBaseImage{}, err
+		}
+	} else {
+		options.skipStages("no registry output", StagePush)
+	}
+
+	if err := fn.writeSBOM(ctx, options, platform, functionImage); err != nil {
+		return image.FunctionImage{}, pinnedBaseImage{}, err
+	}
+	return functionImage, base, nil
+}
+
+// ExportContainerfile compiles the function like Build does, then writes to destination a Containerfile
+// equivalent to the image build together with the files it adds, so the image can be built with any OCI builder
+func ExportContainerfile(ctx context.Context, options BuildOptions, destination string) error {
+	var hashes buildHashes
+	fn, err := prepare(ctx, options, func(fn preparedFunction) error {
+		var err error
+		hashes, err = computeFingerprint(ctx, fn, options)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(options.Platforms) > 1 {
+		return fmt.Errorf("the Containerfile can be exported for a single platform")
+	}
+	platform := options.platforms()[0]
 
-	err = languageManager.DownloadRuntimeIfRequired()
+	recipe, err := fn.compile(ctx, options, platform)
 	if err != nil {
-		return image.FunctionImage{}, err
+		return err
 	}
+	recipe, _, err = fn.withBaseImage(ctx, options, recipe)
+	if err != nil {
+		return err
+	}
+	recipe = withLabels(recipe, fn.metadata(ctx, options, hashes).Labels())
+
+	return options.runStage(ctx, StageExport, func() error {
+		log.Infof("Writing %s to %s", util.Containerfile, destination)
+		return util.WriteBuildContext(recipe, platform, fn.targetDir, destination)
+	})
+}
+
+// runtimeLocks holds a mutex per language, so the concurrent builds download each runtime once
+var runtimeLocks sync.Map
+
+// lockRuntime locks the runtime of the language, returning the function unlocking it
+func lockRuntime(language languages.Language) func() {
+	lock, _ := runtimeLocks.LoadOrStore(language, &sync.Mutex{})
+	mutex := lock.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+// preparedFunction is a function resolved and configured, ready to be compiled
+type preparedFunction struct {
+	function        languages.Function
+	language        languages.Language
+	languageManager languages.LanguageManager
+	configuration   languages.Configuration
+	targetDir       string
+}
 
-	log.Info("Checking compile dependencies")
+// prepare creates the directories, downloads the function and the runtime and loads the configuration.
+// onConfigured, if not nil, runs as part of the configuration stage
+func prepare(ctx context.Context, options BuildOptions, onConfigured func(preparedFunction) error) (preparedFunction, error) {
+	location := options.Location
+	targetDir := config.GetTargetDir(location)
+
+	err := util.MkdirpIfNotExists(targetDir)
+	if err != nil {
+		return preparedFunction{}, err
+	}
 
-	err = languageManager.CheckCompileDependencies()
+	err = util.MkdirpIfNotExists(config.RuntimeDir)
 	if err != nil {
-		return image.FunctionImage{}, err
+		return preparedFunction{}, err
 	}
 
 	if strings.HasPrefix(location, "http") {
-		log.Infof("Downloading function from %s", location)
+		err = options.runStage(ctx, StageDownloadFunction, func() error {
+			log.Infof("Downloading function from %s", location)
 
-		location, err = downloadFunctionFromHTTP(location, languages.GetExtension(language))
-		if err != nil {
-			return image.FunctionImage{}, err
-		}
+			location, err = downloadFunctionFromHTTP(ctx, location, options.Language)
+			if err != nil {
+				return err
+			}
 
-		location, err = filepath.Abs(location)
+			location, err = filepath.Abs(location)
+			return err
+		})
 		if err != nil {
-			return image.FunctionImage{}, err
+			return preparedFunction{}, err
 		}
+	}
 
-	} else {
-		if !util.FileExist(location) {
-			return image.FunctionImage{}, fmt.Errorf("cannot find file %s", location)
+	function, err := languages.ResolveFunction(location, options.Entry)
+	if err != nil {
+		return preparedFunction{}, err
+	}
+
+	language := options.Language
+	if language == languages.Unknown {
+		language, err = languages.DetectLanguage(function.MainFile)
+		if err != nil {
+			return preparedFunction{}, err
 		}
 	}
 
-	log.Infof("Retrieving function configuration")
+	languageManager := languages.ResolveLanguageManager(language)
+	if languageManager == nil {
+		return preparedFunction{}, fmt.Errorf("unknown language %s", language)
+	}
 
-	functionConfiguration, err := util.ParseConfigComments(languages.GetLineComment(language), location)
+	err = options.runStage(ctx, StageRuntimeDownload, func() error {
+		unlock := lockRuntime(language)
+		defer unlock()
+		return languageManager.DownloadRuntimeIfRequired(ctx)
+	})
 	if err != nil {
-		return image.FunctionImage{}, err
+		return preparedFunction{}, err
 	}
 
-	// Log only if needed
-	if config.Verbose {
-		for k, v := range functionConfiguration {
-			log.Infof("Configuration entry %s: %s", k, v)
+	fn := preparedFunction{function: function, language: language, languageManager: languageManager, targetDir: targetDir}
+	err = options.runStage(ctx, StageConfiguration, func() error {
+		log.Infof("Retrieving function configuration")
+
+		fn.configuration, err = languages.LoadConfiguration(language, function)
+		if err != nil {
+			return err
 		}
-	}
 
-	log.Info("Configuring target directory")
+		// Log only if needed
+		if config.Verbose {
+			for _, k := range fn.configuration.Keys() {
+				log.Infof("Configuration entry %s: %s", k, fn.configuration.Strings(k))
+			}
+		}
 
-	err = languageManager.ConfigureTargetDirectory(location, functionConfiguration, targetDir)
+		if onConfigured != nil {
+			return onConfigured(fn)
+		}
+		return nil
+	})
 	if err != nil {
-		return image.FunctionImage{}, err
+		return preparedFunction{}, err
 	}
 
-	log.Info("Compiling")
+	return fn, nil
+}
+
+// compile checks the dependencies, configures the target directory and compiles the function for the platform,
+// returning the recipe of its image
+func (fn preparedFunction) compile(ctx context.Context, options BuildOptions, platform image.Platform) (util.ImageRecipe, error) {
+	err := options.runStage(ctx, StageDependencyCheck, func() error {
+		log.Info("Checking compile dependencies")
+		return fn.languageManager.CheckCompileDependencies()
+	})
+	if err != nil {
+		return util.ImageRecipe{}, err
+	}
 
-	compiledOutput, additionalFiles, err := languageManager.Compile(location, functionConfiguration, targetDir)
+	err = options.runStage(ctx, StageConfigure, func() error {
+		log.Info("Configuring target directory")
+		return fn.languageManager.ConfigureTargetDirectory(fn.function, fn.configuration, fn.targetDir)
+	})
 	if err != nil {
-		return image.FunctionImage{}, err
+		return util.ImageRecipe{}, err
 	}
 
-	log.Info("Starting build image")
+	var recipe util.ImageRecipe
+	err = options.runStage(ctx, StageCompile, func() error {
+		log.Info("Compiling")
+		compiledOutput, additionalFiles, err := fn.languageManager.Compile(ctx, fn.function, fn.configuration, platform, fn.targetDir)
+		if err != nil {
+			return err
+		}
 
-	return languageManager.BuildImage(systemContext, imageName, imageTag, compiledOutput, additionalFiles, targetDir)
+		recipe, err = fn.languageManager.ImageRecipe(ctx, compiledOutput, additionalFiles, fn.targetDir)
+		return err
+	})
+	if err != nil {
+		return util.ImageRecipe{}, err
+	}
+
+	return recipe, nil
 }
 
-func downloadFunctionFromHTTP(remote, extension string) (string, error) {
-	f, err := ioutil.TempFile("", fmt.Sprintf("*.%d", extension))
+// When the language is unknown, the temp file keeps the remote extension to allow the detection
+func downloadFunctionFromHTTP(ctx context.Context, remote string, language languages.Language) (string, error) {
+	extension := path.Ext(remote)
+	if language != languages.Unknown {
+		extension = "." + languages.GetExtension(language)
+	}
+
+	f, err := ioutil.TempFile("", "*"+extension)
 	if err != nil {
 		return "", err
 	}
 
-	err = util.DownloadAndPipe(remote, f)
+	err = util.DownloadAndPipe(ctx, remote, f)
 	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
 		return "", err
 	}
 	err = f.Close()
@@ -0,0 +1,402 @@
+package languages
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+const (
+	DependencyKey = "dependency"
+	LanguageKey   = "language"
+	EnvKey        = "env"
+	MinScaleKey   = "min-scale"
+	MaxScaleKey   = "max-scale"
+	BaseImageKey  = "base-image"
+	TriggerKey    = "trigger"
+)
+
+// ValueType is the type of the value of a configuration key
+type ValueType string
+
+const (
+	StringValue ValueType = "string"
+	BoolValue   ValueType = "bool"
+	IntValue    ValueType = "int"
+	// EnvValue is an environment variable in the form NAME=VALUE
+	EnvValue ValueType = "env"
+	// DependencyValue is a dependency in the form `name version`
+	DependencyValue ValueType = "dependency"
+	// TriggerValue is a Knative Trigger in the form `attribute=value ...`, where broker=<name> selects the broker
+	TriggerValue ValueType = "trigger"
+)
+
+// Broker of the triggers not declaring one
+const defaultBroker = "default"
+
+// ConfigKey declares a `kfn:<name>` configuration key and how to validate its values
+type ConfigKey struct {
+	Name string
+	Type ValueType
+	// Repeatable keys can be declared more than once
+	Repeatable bool
+	// Allowed values, when empty any value of the right type is allowed
+	Allowed     []string
+	Description string
+}
+
+// Keys available for every language
+var commonConfigKeys = []ConfigKey{
+	{Name: DependencyKey, Type: DependencyValue, Repeatable: true, Description: "Function dependency, in the form `name version`"},
+	{Name: LanguageKey, Type: StringValue, Description: "Function language, overrides the detected one"},
+	{Name: EnvKey, Type: EnvValue, Repeatable: true, Description: "Environment variable of the deployed function"},
+	{Name: MinScaleKey, Type: IntValue, Description: "Minimum number of replicas of the deployed function"},
+	{Name: MaxScaleKey, Type: IntValue, Description: "Maximum number of replicas of the deployed function"},
+	{Name: BaseImageKey, Type: StringValue, Description: "Base image of the function image, overrides the one of the language. Use scratch for an empty base"},
+	{Name: TriggerKey, Type: TriggerValue, Repeatable: true, Description: "Knative Trigger subscribing the deployed function to the events matching all the `attribute=value` filters"},
+}
+
+// ConfigEntry is a configuration value together with its position in the function file
+type ConfigEntry struct {
+	Key string
+	// Value split in arguments, with quotes removed
+	Args []string
+	File string
+	Line int
+}
+
+func (e ConfigEntry) Value() string {
+	return strings.Join(e.Args, " ")
+}
+
+// Position returns file:line of the entry, or only the file when the line is unknown
+func (e ConfigEntry) Position() string {
+	if e.Line == 0 {
+		return e.File
+	}
+	return fmt.Sprintf("%s:%d", e.File, e.Line)
+}
+
+type Dependency struct {
+	Name    string
+	Version string
+}
+
+// Configuration is the validated function configuration
+type Configuration struct {
+	entries map[string][]ConfigEntry
+	// Declaration of the configured keys
+	keys map[string]ConfigKey
+}
+
+func NewConfiguration() Configuration {
+	return Configuration{entries: make(map[string][]ConfigEntry), keys: make(map[string]ConfigKey)}
+}
+
+func (c Configuration) Has(key string) bool {
+	return len(c.entries[key]) != 0
+}
+
+func (c Configuration) Entries(key string) []ConfigEntry {
+	return c.entries[key]
+}
+
+// Keys returns the configured keys, sorted by name
+func (c Configuration) Keys() []string {
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c Configuration) String(key string, defaultValue string) string {
+	if !c.Has(key) {
+		return defaultValue
+	}
+	return c.entries[key][0].Value()
+}
+
+func (c Configuration) Strings(key string) []string {
+	values := make([]string, 0, len(c.entries[key]))
+	for _, e := range c.entries[key] {
+		values = append(values, e.Value())
+	}
+	return values
+}
+
+// Bool returns the value of a BoolValue key, already validated while parsing
+func (c Configuration) Bool(key string, defaultValue bool) bool {
+	if !c.Has(key) {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(c.entries[key][0].Value())
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+// Int returns the value of a IntValue key, already validated while parsing
+func (c Configuration) Int(key string, defaultValue int) int {
+	if !c.Has(key) {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(c.entries[key][0].Value())
+	if err != nil {
+		return defaultValue
+	}
+	return i
+}
+
+// Triggers returns the triggers of the deployed function, already validated while parsing
+func (c Configuration) Triggers() []image.Trigger {
+	triggers := make([]image.Trigger, 0, len(c.entries[TriggerKey]))
+	for _, e := range c.entries[TriggerKey] {
+		trigger := image.Trigger{Broker: defaultBroker, Attributes: make(map[string]string)}
+		for _, arg := range e.Args {
+			kv := strings.SplitN(arg, "=", 2)
+			if kv[0] == "broker" {
+				trigger.Broker = kv[1]
+			} else {
+				trigger.Attributes[kv[0]] = kv[1]
+			}
+		}
+		triggers = append(triggers, trigger)
+	}
+	return triggers
+}
+
+func (c Configuration) Dependencies() []Dependency {
+	deps := make([]Dependency, 0, len(c.entries[DependencyKey]))
+	for _, e := range c.entries[DependencyKey] {
+		deps = append(deps, Dependency{Name: e.Args[0], Version: e.Args[1]})
+	}
+	return deps
+}
+
+// ToMap returns the values grouped by key, with the arguments joined by a space
+func (c Configuration) ToMap() map[string][]string {
+	result := make(map[string][]string, len(c.entries))
+	for k := range c.entries {
+		result[k] = c.Strings(k)
+	}
+	return result
+}
+
+func (c Configuration) add(key ConfigKey, entry ConfigEntry) {
+	c.keys[key.Name] = key
+	c.entries[entry.Key] = append(c.entries[entry.Key], entry)
+}
+
+// ConfigError is a configuration error, reported with the position of the wrong entry
+type ConfigError struct {
+	File    string
+	Line    int
+	Key     string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	position := ConfigEntry{File: e.File, Line: e.Line}.Position()
+	return fmt.Sprintf("%s: kfn:%s %s", position, e.Key, e.Message)
+}
+
+// ConfigErrors collects all the errors found in the function configuration
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "invalid function configuration:\n" + strings.Join(messages, "\n")
+}
+
+// ConfigKeys returns the keys available for the language, including the common ones
+func ConfigKeys(language Language) []ConfigKey {
+	keys := append([]ConfigKey{}, commonConfigKeys...)
+	if d, ok := GetDescriptor(language); ok {
+		keys = append(keys, d.ConfigKeys...)
+	}
+	return keys
+}
+
+// ParseConfiguration parses and validates the `kfn:` comments of the function file.
+// Values are split like shell arguments, so values with spaces can be quoted.
+// Unknown keys are reported as warnings and skipped
+func ParseConfiguration(language Language, functionFile string) (Configuration, error) {
+	schema := make(map[string]ConfigKey)
+	for _, k := range ConfigKeys(language) {
+		schema[k.Name] = k
+	}
+
+	f, err := os.Open(functionFile)
+	if err != nil {
+		return Configuration{}, err
+	}
+	defer f.Close()
+
+	// Group 1 contains key, Group 2 contains value
+	commentRegex := regexp.MustCompile(`^[[:space:]]*` + regexp.QuoteMeta(GetLineComment(language)) + `[[:space:]]*kfn:([a-zA-Z\-]+)(?:[[:space:]]+(.*))?$`)
+
+	configuration := NewConfiguration()
+	errs := make(ConfigErrors, 0)
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		submatch := commentRegex.FindStringSubmatch(scanner.Text())
+		if submatch == nil {
+			continue
+		}
+
+		entry := ConfigEntry{Key: submatch[1], File: functionFile, Line: lineNumber}
+
+		key, ok := schema[entry.Key]
+		if !ok {
+			log.Warnf("%s: unknown configuration key kfn:%s", entry.Position(), entry.Key)
+			continue
+		}
+
+		entry.Args, err = splitArgs(submatch[2])
+		if err == nil {
+			err = validateEntry(key, entry, configuration)
+		}
+		if err != nil {
+			errs = append(errs, ConfigError{File: functionFile, Line: lineNumber, Key: entry.Key, Message: err.Error()})
+			continue
+		}
+
+		configuration.add(key, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return Configuration{}, err
+	}
+
+	if len(errs) != 0 {
+		return Configuration{}, errs
+	}
+	return configuration, nil
+}
+
+func validateEntry(key ConfigKey, entry ConfigEntry, configuration Configuration) error {
+	if !key.Repeatable && configuration.Has(key.Name) {
+		return fmt.Errorf("is declared more than once, previous declaration at %s", configuration.Entries(key.Name)[0].Position())
+	}
+
+	if key.Type == TriggerValue {
+		return validateTrigger(entry)
+	}
+
+	expectedArgs := 1
+	if key.Type == DependencyValue {
+		expectedArgs = 2
+	}
+	if len(entry.Args) != expectedArgs {
+		return fmt.Errorf("expects %d value(s) of type %s, found %d. Quote values containing spaces", expectedArgs, key.Type, len(entry.Args))
+	}
+
+	value := entry.Args[0]
+	switch key.Type {
+	case BoolValue:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("expects a boolean, found '%s'", value)
+		}
+	case IntValue:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("expects an integer, found '%s'", value)
+		}
+	case EnvValue:
+		if i := strings.Index(value, "="); i <= 0 {
+			return fmt.Errorf("expects an environment variable in the form NAME=VALUE, found '%s'", value)
+		}
+	}
+
+	if len(key.Allowed) != 0 {
+		for _, a := range key.Allowed {
+			if a == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("has unsupported value '%s', allowed values: %s", value, strings.Join(key.Allowed, ", "))
+	}
+
+	return nil
+}
+
+func validateTrigger(entry ConfigEntry) error {
+	if len(entry.Args) == 0 {
+		return fmt.Errorf("expects at least a filter in the form attribute=value")
+	}
+	seen := make(map[string]bool, len(entry.Args))
+	for _, arg := range entry.Args {
+		i := strings.Index(arg, "=")
+		if i <= 0 || i == len(arg)-1 {
+			return fmt.Errorf("expects filters in the form attribute=value, found '%s'", arg)
+		}
+		if seen[arg[:i]] {
+			return fmt.Errorf("declares the attribute %s more than once", arg[:i])
+		}
+		seen[arg[:i]] = true
+	}
+	return nil
+}
+
+// splitArgs splits the value like a shell: arguments are separated by spaces, single quotes
+// keep the content as is and double quotes allow to escape " and \ with \
+func splitArgs(value string) ([]string, error) {
+	args := make([]string, 0)
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(value)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("has an unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,167 @@
+package languages
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+// Function locates the sources of a function
+type Function struct {
+	// Entry file of the function, where the kfn configuration comments are parsed
+	MainFile string
+	// Root directory of a multi-file function, empty for single file functions
+	Directory string
+}
+
+func (f Function) IsDirectory() bool {
+	return f.Directory != ""
+}
+
+// Location identifies the function, so it's used to compute the target and editing directories
+func (f Function) Location() string {
+	if f.IsDirectory() {
+		return f.Directory
+	}
+	return f.MainFile
+}
+
+// Entry returns the path of the main file relative to the function directory
+func (f Function) Entry() string {
+	if !f.IsDirectory() {
+		return path.Base(f.MainFile)
+	}
+	rel, err := filepath.Rel(f.Directory, f.MainFile)
+	if err != nil {
+		return path.Base(f.MainFile)
+	}
+	return rel
+}
+
+// ResolveFunction resolves the function at the provided location. When the location is a directory
+// the entry file is the provided one, otherwise it's looked up between the entry files of the registered languages
+func ResolveFunction(location string, entry string) (Function, error) {
+	location, err := filepath.Abs(location)
+	if err != nil {
+		return Function{}, err
+	}
+
+	if util.FileExist(location) {
+		return Function{MainFile: location}, nil
+	}
+
+	if !util.DirExist(location) {
+		return Function{}, fmt.Errorf("cannot find %s", location)
+	}
+
+	if entry != "" {
+		if !util.FileExist(location, entry) {
+			return Function{}, fmt.Errorf("cannot find entry file %s in %s", entry, location)
+		}
+		return Function{MainFile: path.Join(location, entry), Directory: location}, nil
+	}
+
+	found := entryFiles(location, Descriptors())
+	if len(found) == 0 && loadLanguages() {
+		found = entryFiles(location, Descriptors())
+	}
+
+	switch len(found) {
+	case 0:
+		return Function{}, fmt.Errorf("cannot find an entry file in %s, declare it with --entry", location)
+	case 1:
+		return Function{MainFile: path.Join(location, found[0]), Directory: location}, nil
+	default:
+		return Function{}, fmt.Errorf("found multiple entry files in %s (%s), declare the right one with --entry", location, strings.Join(found, ", "))
+	}
+}
+
+// entryFiles returns the entry files of the languages found in the directory
+func entryFiles(directory string, descriptors []Descriptor) []string {
+	found := make([]string, 0)
+	for _, d := range descriptors {
+		for _, e := range d.EntryFiles {
+			if util.FileExist(directory, e) {
+				found = append(found, e)
+			}
+		}
+	}
+	return found
+}
+
+// CopySources copies the function directory into dest, honoring the .kfnignore file.
+// Additional ignore patterns can be provided, for example to skip files generated by the language manager
+func (f Function) CopySources(dest string, ignorePatterns ...string) error {
+	ignore, err := util.LoadIgnoreFile(f.Directory)
+	if err != nil {
+		return err
+	}
+	return util.CopyTree(f.Directory, dest, ignore.With(ignorePatterns...))
+}
+
+// LinkSources links the top level entries of the function directory into dest, honoring the .kfnignore file.
+// Files generated in dest by the language manager must be ignored, to avoid overwriting the linked sources
+func (f Function) LinkSources(dest string, ignorePatterns ...string) error {
+	ignore, err := util.LoadIgnoreFile(f.Directory)
+	if err != nil {
+		return err
+	}
+	return util.LinkContent(f.Directory, dest, ignore.With(ignorePatterns...))
+}
+
+// Directories never containing functions, skipped by FindFunctions together with the hidden ones
+var skippedDirectories = map[string]bool{"node_modules": true, "target": true, "vendor": true}
+
+// FindFunctions looks for the functions in the root directory and its subdirectories.
+// A directory containing an entry file of a language is a multi-file function and it's not visited further,
+// while a file with the extension of a language is a single file function only when it's marked for kfn,
+// so the helper modules next to the functions are not built
+func FindFunctions(root string) ([]string, error) {
+	descriptors := AllDescriptors()
+	var functions []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			marked, err := isMarkedFunction(p)
+			if err != nil {
+				return err
+			}
+			if marked {
+				functions = append(functions, p)
+			}
+			return nil
+		}
+
+		if p != root && (strings.HasPrefix(info.Name(), ".") || skippedDirectories[info.Name()]) {
+			return filepath.SkipDir
+		}
+		if len(entryFiles(p, descriptors)) != 0 {
+			functions = append(functions, p)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return functions, err
+}
+
+// A file is marked for kfn by the `kfn:language` comment or by its sidecar file
+func isMarkedFunction(file string) (bool, error) {
+	if GetLanguage(path.Ext(file)) == Unknown {
+		return false, nil
+	}
+	if util.FileExist(SidecarFile(Function{MainFile: file})) {
+		return true, nil
+	}
+	header, err := readHeader(file)
+	if err != nil {
+		return false, err
+	}
+	return languageFromComment(header) != "", nil
+}
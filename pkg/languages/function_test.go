@@ -0,0 +1,73 @@
+package languages
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// Only the descriptor is used to find the functions
+type descriptorManager struct {
+	LanguageManager
+	descriptor Descriptor
+}
+
+func (m descriptorManager) Descriptor() Descriptor {
+	return m.descriptor
+}
+
+func registerJavascript(t *testing.T) {
+	if _, ok := GetDescriptor(Javascript); ok {
+		return
+	}
+	err := RegisterLanguageManager(descriptorManager{descriptor: Descriptor{
+		Name:        Javascript,
+		Extensions:  []string{".js"},
+		LineComment: "//",
+		EntryFiles:  []string{"index.js"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindFunctionsSkipsHelperModules(t *testing.T) {
+	registerJavascript(t)
+
+	root, err := ioutil.TempDir("", "kfn-find")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"commented.js":          "// kfn:language js\nmodule.exports = (event) => event;\n",
+		"configured.js":         "module.exports = (event) => event;\n",
+		"configured.kfn.yaml":   "min-scale: 1\n",
+		"helper.js":             "module.exports.upper = (s) => s.toUpperCase();\n",
+		"multi/index.js":        "module.exports = (event) => event;\n",
+		"multi/lib/strings.js":  "// kfn:language js\n",
+		"nested/other/utils.js": "module.exports = {};\n",
+	}
+	for name, content := range files {
+		p := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	functions, err := FindFunctions(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{path.Join(root, "commented.js"), path.Join(root, "configured.js"), path.Join(root, "multi")}
+	if !reflect.DeepEqual(functions, expected) {
+		t.Errorf("expected the functions %v, got %v", expected, functions)
+	}
+}
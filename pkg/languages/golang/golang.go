@@ -0,0 +1,236 @@
+package golang
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+const (
+	runtimeModule     = "kfn-function"
+	buildEnvVariables = "build-env"
+)
+
+var descriptor = languages.Descriptor{
+	Name:        languages.Go,
+	LongName:    "Go",
+	Extensions:  []string{".go"},
+	LineComment: "//",
+	Template:    "function.go.tmpl",
+	EntryFiles:  []string{"function.go"},
+	ConfigKeys: []languages.ConfigKey{
+		{Name: buildEnvVariables, Type: languages.EnvValue, Repeatable: true, Description: "Environment variable for go build"},
+	},
+}
+
+type goLanguageManager struct {
+	resourceLoader util.ResourceLoader
+}
+
+func NewGoLanguageManager() languages.LanguageManager {
+	return goLanguageManager{util.NewResourceLoader("../../templates/go")}
+}
+
+func (g goLanguageManager) Descriptor() languages.Descriptor {
+	return descriptor
+}
+
+func (g goLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
+	err := util.MkdirpIfNotExists(targetDirectory)
+	if err != nil {
+		return err
+	}
+
+	main, err := g.resourceLoader.LoadResource(descriptor.Template)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(
+		targetDirectory,
+		util.WriteDest{Filename: functionName + descriptor.Extensions[0], Data: main},
+	)
+}
+
+func (g goLanguageManager) CheckCompileDependencies() error {
+	return util.CommandsExists("go")
+}
+
+// DownloadRuntimeIfRequired is not used in the Go runtime, since the wrapper is shipped within kfn
+func (g goLanguageManager) DownloadRuntimeIfRequired(ctx context.Context) error {
+	return nil
+}
+
+// The editing directory is a go module containing only the function package, so gopls can resolve the dependencies
+func (g goLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
+	functionFile := path.Join(editingDirectory, "function.go")
+
+	goMod, err := generateGoMod(path.Join(runtimeModule, "function"), functionConfiguration)
+	if err != nil {
+		return "", err
+	}
+
+	err = util.WriteFiles(editingDirectory, util.WriteDest{Filename: "go.mod", Data: goMod})
+	if err != nil {
+		return "", err
+	}
+
+	if function.IsDirectory() {
+		err = function.LinkSources(editingDirectory, "/go.mod", "/go.sum")
+	} else {
+		err = util.Link(function.MainFile, functionFile)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return editingDirectory, nil
+}
+
+// A multi-file function is copied as is in the function package, so its sub packages are
+// importable as kfn-function/function/<sub package>
+func (g goLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	if err := util.CleanDirectory(path.Join(targetDirectory, "function")); err != nil {
+		return err
+	}
+
+	var err error
+	if function.IsDirectory() {
+		err = function.CopySources(path.Join(targetDirectory, "function"), "/go.mod", "/go.sum", "/vendor/")
+	} else {
+		err = util.Copy(function.MainFile, path.Join(targetDirectory, "function", "function.go"))
+	}
+	if err != nil {
+		return err
+	}
+
+	goMod, err := generateGoMod(runtimeModule, functionConfiguration)
+	if err != nil {
+		return err
+	}
+
+	runtimeMain, err := g.resourceLoader.LoadResource("main.go.tmpl")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(
+		targetDirectory,
+		util.WriteDest{Filename: "go.mod", Data: goMod},
+		util.WriteDest{Filename: "main.go", Data: runtimeMain},
+	)
+}
+
+func (g goLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	env := os.Environ()
+	env = append(env, "CGO_ENABLED=0", "GOOS="+platform.OSOrDefault(), "GOARCH="+platform.ArchitectureOrDefault())
+	if platform.Architecture == "arm" && platform.Variant != "" {
+		// GOARM takes the version number of the variant, like 7 for v7
+		env = append(env, "GOARM="+strings.TrimPrefix(platform.Variant, "v"))
+	}
+
+	for _, e := range functionConfiguration.Strings(buildEnvVariables) {
+		log.Printf("Adding env variable to go build: %s", e)
+		env = append(env, e)
+	}
+
+	output := path.Join(targetDirectory, "bin", "function")
+
+	// Tidy resolves the dependencies not pinned in go.mod and generates the go.sum
+	commands := [][]string{
+		{"go", "mod", "tidy"},
+		{"go", "build", "-ldflags", "-s -w", "-o", output, "."},
+	}
+
+	for _, c := range commands {
+		compileCommand := exec.Command(c[0], c[1:]...)
+		compileCommand.Dir = targetDirectory
+		compileCommand.Stdout = config.GetLoggerWriter()
+		compileCommand.Stderr = config.GetLoggerWriter()
+		compileCommand.Env = env
+
+		if err := util.RunProcess(ctx, compileCommand); err != nil {
+			return "", nil, errors.Wrap(err, fmt.Sprintf("error occurred while running '%s'", strings.Join(c, " ")))
+		}
+	}
+
+	return output, nil, nil
+}
+
+// The go.sum is generated by go mod tidy while compiling
+func (g goLanguageManager) ResolvedDependencies(ctx context.Context, functionConfiguration languages.Configuration, targetDirectory string) ([]sbom.Component, error) {
+	goSum := path.Join(targetDirectory, "go.sum")
+	if !util.FsExist(goSum) {
+		return nil, nil
+	}
+	return sbom.ParseGoSum(goSum)
+}
+
+// The go modules are downloaded by the go toolchain on the host, vendoring them is not implemented yet
+func (g goLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	return languages.VendorNotSupported(descriptor)
+}
+
+// The function is compiled with the go toolchain of the host, so its version is part of the fingerprint
+func (g goLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	goVersion, err := exec.CommandContext(ctx, "go", "version").Output()
+	if err != nil {
+		return "", errors.Wrap(err, "error occurred while running 'go version'")
+	}
+
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddBytes(goVersion)
+	if err := fingerprint.AddResources(g.resourceLoader, "main.go.tmpl"); err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
+func (g goLanguageManager) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	return util.ImageRecipe{
+		Port:       "8080",
+		Add:        []util.BuildAdd{{From: mainExecutable, To: "/"}},
+		User:       "1000",
+		Entrypoint: []string{},
+		Cmd:        []string{"/function"},
+	}, nil
+}
+
+// Every dependency entry is in the form `module version`. Dependencies with version `latest`
+// are not pinned and are resolved by `go mod tidy`
+func generateGoMod(module string, configuration languages.Configuration) ([]byte, error) {
+	var goMod strings.Builder
+
+	goMod.WriteString(fmt.Sprintf("module %s\n\ngo 1.12\n", module))
+
+	if deps := configuration.Dependencies(); len(deps) != 0 {
+		requires := make([]string, 0, len(deps))
+		for _, dep := range deps {
+			if dep.Version == "latest" {
+				continue
+			}
+			requires = append(requires, fmt.Sprintf("\t%s %s\n", dep.Name, dep.Version))
+		}
+
+		if len(requires) != 0 {
+			goMod.WriteString("\nrequire (\n")
+			for _, r := range requires {
+				goMod.WriteString(r)
+			}
+			goMod.WriteString(")\n")
+		}
+	}
+
+	return []byte(goMod.String()), nil
+}
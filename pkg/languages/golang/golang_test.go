@@ -0,0 +1,57 @@
+package golang
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+// Renaming a file of the function must not leave the old copy, which would redeclare its functions
+func TestConfigureTargetDirectoryRemovesRenamedSources(t *testing.T) {
+	functionDir, err := ioutil.TempDir("", "kfn-function")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(functionDir)
+	targetDir, err := ioutil.TempDir("", "kfn-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(targetDir)
+
+	err = util.WriteFiles(functionDir,
+		util.WriteDest{Filename: "function.go", Data: []byte("package function\n")},
+		util.WriteDest{Filename: "helper.go", Data: []byte("package function\n\nfunc answer() int { return 42 }\n")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	function, err := languages.ResolveFunction(functionDir, "function.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewGoLanguageManager()
+
+	if err := manager.ConfigureTargetDirectory(function, languages.NewConfiguration(), targetDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(path.Join(functionDir, "helper.go"), path.Join(functionDir, "answer.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ConfigureTargetDirectory(function, languages.NewConfiguration(), targetDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if util.FileExist(targetDir, "function", "helper.go") {
+		t.Error("helper.go was renamed but it's still in the target directory")
+	}
+	if !util.FileExist(targetDir, "function", "answer.go") {
+		t.Error("answer.go is missing from the target directory")
+	}
+}
@@ -0,0 +1,237 @@
+package java
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+const (
+	builderImage = "maven:3.6-jdk-11"
+	baseImage    = "openjdk:11-jre-slim"
+)
+
+var descriptor = languages.Descriptor{
+	Name:         languages.Java,
+	LongName:     "Java",
+	Extensions:   []string{".java"},
+	LineComment:  "//",
+	Interpreters: []string{"java"},
+	Template:     "Function.java",
+	EntryFiles:   []string{"Function.java"},
+}
+
+type javaLanguageManager struct {
+	resourceLoader util.ResourceLoader
+}
+
+func NewJavaLanguageManager() languages.LanguageManager {
+	return javaLanguageManager{util.NewResourceLoader("../../templates/java")}
+}
+
+func (j javaLanguageManager) Descriptor() languages.Descriptor {
+	return descriptor
+}
+
+func (j javaLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
+	err := util.MkdirpIfNotExists(targetDirectory)
+	if err != nil {
+		return err
+	}
+
+	main, err := j.resourceLoader.LoadResource(descriptor.Template)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(
+		targetDirectory,
+		util.WriteDest{Filename: functionName + descriptor.Extensions[0], Data: main},
+	)
+}
+
+// For Java nothing is required, since the compilation is done inside the builder container
+func (j javaLanguageManager) CheckCompileDependencies() error {
+	return nil
+}
+
+// DownloadRuntimeIfRequired is not used in the Java runtime, since the wrapper is shipped within kfn
+func (j javaLanguageManager) DownloadRuntimeIfRequired(ctx context.Context) error {
+	return nil
+}
+
+// The editing directory is a maven project, so it can be imported by IDEs
+func (j javaLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
+	sourcesDir := path.Join(editingDirectory, "src", "main", "java")
+	if err := util.MkdirpIfNotExists(sourcesDir); err != nil {
+		return "", err
+	}
+
+	if err := j.writeProject(functionConfiguration, editingDirectory); err != nil {
+		return "", err
+	}
+
+	if function.IsDirectory() {
+		if err := checkEntry(function); err != nil {
+			return "", err
+		}
+		if err := function.LinkSources(sourcesDir, "/Main.java", "/pom.xml", "target/"); err != nil {
+			return "", err
+		}
+	} else if err := util.Link(function.MainFile, path.Join(sourcesDir, "Function.java")); err != nil {
+		return "", err
+	}
+
+	return editingDirectory, nil
+}
+
+// A multi-file function directory is the root of the java sources, so the other classes can be placed
+// in the default package near Function.java or in packages
+func (j javaLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	projectDir := path.Join(targetDirectory, "project")
+	sourcesDir := path.Join(projectDir, "src", "main", "java")
+	// The maven target directory is kept, to compile only the changed sources
+	if err := util.CleanDirectory(path.Join(projectDir, "src")); err != nil {
+		return err
+	}
+	if err := util.MkdirpIfNotExists(sourcesDir); err != nil {
+		return err
+	}
+
+	if err := j.writeProject(functionConfiguration, projectDir); err != nil {
+		return err
+	}
+
+	if function.IsDirectory() {
+		if err := checkEntry(function); err != nil {
+			return err
+		}
+		return function.CopySources(sourcesDir, "/Main.java", "/pom.xml", "target/")
+	}
+
+	return util.Copy(function.MainFile, path.Join(sourcesDir, "Function.java"))
+}
+
+// The wrapper invokes the Function class in the default package, so the entry file name can't be changed
+func checkEntry(function languages.Function) error {
+	if function.Entry() != "Function.java" {
+		return fmt.Errorf("the entry file of a java function must be Function.java in the root of the function directory, found %s", function.Entry())
+	}
+	return nil
+}
+
+// Compile runs maven inside the builder image. The local maven repository is cached in the kfn directory
+func (j javaLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	projectDir := path.Join(targetDirectory, "project")
+	mavenRepository := path.Join(config.CacheDir, "m2")
+
+	if err := util.MkdirpIfNotExists(mavenRepository); err != nil {
+		return "", nil, err
+	}
+
+	err := util.RunInContainer(
+		ctx,
+		config.BuildSystemContext,
+		builderImage,
+		[]util.BuildMount{
+			{Source: projectDir, Destination: "/project"},
+			{Source: mavenRepository, Destination: "/root/.m2"},
+		},
+		util.BuildCommand{Command: "mvn -B -q package", Wd: "/project"},
+	)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error occurred while compiling with maven")
+	}
+
+	return path.Join(projectDir, "target", "function.jar"), nil, nil
+}
+
+func (j javaLanguageManager) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	return util.ImageRecipe{
+		BaseImage: baseImage,
+		Port:      "8080",
+		Add:       []util.BuildAdd{{From: mainExecutable, To: "/deployments/function.jar"}},
+		User:      "1001",
+		WorkDir:   "/deployments",
+		Cmd:       []string{"java", "-jar", "/deployments/function.jar"},
+	}, nil
+}
+
+// The maven dependencies are packaged in the jar, but maven doesn't produce a lockfile to list them
+func (j javaLanguageManager) ResolvedDependencies(ctx context.Context, functionConfiguration languages.Configuration, targetDirectory string) ([]sbom.Component, error) {
+	return nil, nil
+}
+
+// The maven dependencies are resolved by the maven builder, vendoring them is not implemented yet
+func (j javaLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	return languages.VendorNotSupported(descriptor)
+}
+
+func (j javaLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddString(builderImage, baseImage)
+	if err := fingerprint.AddResources(j.resourceLoader, "pom.xml", "Main.java"); err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
+// Writes the pom.xml and the runtime wrapper
+func (j javaLanguageManager) writeProject(functionConfiguration languages.Configuration, projectDir string) error {
+	dependencies, err := parseDependencies(functionConfiguration)
+	if err != nil {
+		return err
+	}
+
+	pomTemplate, err := j.resourceLoader.LoadTemplate("pom.xml")
+	if err != nil {
+		return err
+	}
+
+	err = util.PipeTemplateToFile(path.Join(projectDir, "pom.xml"), pomTemplate, struct{ Dependencies []mavenDependency }{dependencies})
+	if err != nil {
+		return err
+	}
+
+	runtimeMain, err := j.resourceLoader.LoadResource("Main.java")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(path.Join(projectDir, "src", "main", "java"), util.WriteDest{Filename: "Main.java", Data: runtimeMain})
+}
+
+type mavenDependency struct {
+	GroupId    string
+	ArtifactId string
+	Version    string
+}
+
+// Every dependency entry is in the form `groupId:artifactId version`
+func parseDependencies(configuration languages.Configuration) ([]mavenDependency, error) {
+	deps := []mavenDependency{
+		{GroupId: "com.fasterxml.jackson.core", ArtifactId: "jackson-databind", Version: "2.10.0"},
+	}
+
+	for _, dep := range configuration.Dependencies() {
+		coordinates := strings.Split(dep.Name, ":")
+		if len(coordinates) != 2 || coordinates[0] == "" || coordinates[1] == "" {
+			return nil, fmt.Errorf("Invalid dependency entry, expecting groupId:artifactId: %v", dep.Name)
+		}
+		deps = append(deps, mavenDependency{
+			GroupId:    coordinates[0],
+			ArtifactId: coordinates[1],
+			Version:    dep.Version,
+		})
+	}
+
+	return deps, nil
+}
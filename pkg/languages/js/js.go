@@ -4,20 +4,38 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path"
-	"strings"
+	"path/filepath"
 
-	"github.com/containers/image/types"
 	"github.com/slinkydeveloper/kfn/pkg/config"
 	"github.com/slinkydeveloper/kfn/pkg/image"
 	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
 	"github.com/slinkydeveloper/kfn/pkg/util"
 )
 
 const (
 	baseImage = "oscf/js-runtime:0.0.2"
+	// Lockfile of the dependencies installed in the image, copied in the target directory
+	installedLockfile = "installed-package-lock.json"
+	// The npm cache of the vendored packages is mounted in the build container at this path
+	npmCacheMount = "/kfn-npm-cache"
 )
 
+// Files describing the dependencies to install
+var packageFiles = []string{"package.json", "package-lock.json", "npm-shrinkwrap.json"}
+
+var descriptor = languages.Descriptor{
+	Name:         languages.Javascript,
+	LongName:     "Javascript",
+	Extensions:   []string{".js", ".mjs", ".cjs"},
+	LineComment:  "//",
+	Interpreters: []string{"node", "nodejs"},
+	Template:     "index.js",
+	EntryFiles:   []string{"index.js"},
+}
+
 type jsLanguageManager struct {
 	resourceLoader util.ResourceLoader
 }
@@ -26,20 +44,24 @@ func NewJsLanguageManger() languages.LanguageManager {
 	return jsLanguageManager{util.NewResourceLoader("../../templates/js")}
 }
 
+func (r jsLanguageManager) Descriptor() languages.Descriptor {
+	return descriptor
+}
+
 func (r jsLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
 	err := util.MkdirpIfNotExists(targetDirectory)
 	if err != nil {
 		return err
 	}
 
-	main, err := r.resourceLoader.LoadResource("index.js")
+	main, err := r.resourceLoader.LoadResource(descriptor.Template)
 	if err != nil {
 		return err
 	}
 
 	return util.WriteFiles(
 		targetDirectory,
-		util.WriteDest{fmt.Sprintf("%s.js", functionName), main},
+		util.WriteDest{Filename: functionName + descriptor.Extensions[0], Data: main},
 	)
 }
 
@@ -48,54 +70,108 @@ func (j jsLanguageManager) CheckCompileDependencies() error {
 	return nil
 }
 
-func (j jsLanguageManager) Compile(mainFile string, functionConfiguration map[string][]string, targetDirectory string) (string, []string, error) {
-	dir, _ := path.Split(mainFile)
+func (j jsLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	dir, _ := path.Split(function.MainFile)
 	packageJson := path.Join(dir, "package.json")
 	if util.FsExist(packageJson) {
-		return mainFile, []string{packageJson}, nil
+		return function.MainFile, []string{packageJson}, nil
 	} else {
-		return mainFile, []string{}, nil
+		return function.MainFile, []string{}, nil
 	}
 }
 
-func (j jsLanguageManager) BuildImage(systemContext *types.SystemContext, imageName string, imageTag string, mainExecutable string, additionalFiles []string, targetDirectory string) (image.FunctionImage, error) {
-	builder, err := util.InitializeBuilder(context.TODO(), systemContext, baseImage)
-	if err != nil {
-		return image.FunctionImage{}, err
+// The dependencies are installed in a base stage containing only package.json and the lockfiles,
+// so it's reused until they change
+func (j jsLanguageManager) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	usrDir := path.Join(targetDirectory, "usr")
+
+	dependencies := util.ImageRecipe{BaseImage: baseImage}
+	for _, f := range packageFiles {
+		if util.FileExist(usrDir, f) {
+			dependencies.Add = append(dependencies.Add, util.BuildAdd{From: path.Join(usrDir, f), To: path.Join("/home/node/usr", f)})
+		}
 	}
 
-	builder.SetPort("8080")
+	install := util.BuildCommand{Command: "npm install", Wd: "/home/node/usr"}
+	if config.Offline {
+		if err := checkNpmCache(); err != nil {
+			return util.ImageRecipe{}, err
+		}
+		install.Command = "npm install --offline --cache " + npmCacheMount
+		install.Mounts = []util.BuildMount{{Source: npmCacheDirectory(), Destination: npmCacheMount}}
+	}
+	dependencies.Run = []util.BuildCommand{install}
+
+	return util.ImageRecipe{
+		BaseStage: &dependencies,
+		Port:      "8080",
+		Add:       []util.BuildAdd{{From: usrDir, To: "/home/node/usr"}},
+		CopyOut:   []util.BuildAdd{{From: "/home/node/usr/package-lock.json", To: path.Join(targetDirectory, installedLockfile)}},
+		Env:       []util.BuildEnv{{Name: "HOME", Value: "/home/node/usr"}},
+		User:      "1001",
+		WorkDir:   "/home/node/src",
+		Cmd:       []string{"node", "/home/node/src/index.js"},
+	}, nil
+}
 
-	err = util.Add(builder, util.BuildAdd{From: path.Join(targetDirectory, "usr"), To: "/home/node/usr"})
-	if err != nil {
-		return image.FunctionImage{}, err
+// The lockfile of the installed dependencies is copied from the image, since npm install updates it or generates it if missing
+func (j jsLanguageManager) ResolvedDependencies(ctx context.Context, functionConfiguration languages.Configuration, targetDirectory string) ([]sbom.Component, error) {
+	lockfile := path.Join(targetDirectory, installedLockfile)
+	if !util.FsExist(lockfile) {
+		return nil, nil
 	}
+	return sbom.ParsePackageLock(lockfile)
+}
 
-	err = util.RunCommands(
-		builder,
-		util.BuildCommand{Command: "npm install", Wd: "/home/node/usr"},
-	)
-	if err != nil {
-		return image.FunctionImage{}, err
+// Vendor installs the dependencies in the base image with the npm cache of the vendor directory, so the following
+// offline installs find the packages in the cache. package.json and the lockfiles are copied in a temporary directory,
+// since node_modules must not end in the usr directory added to the image
+func (j jsLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	if err := util.MkdirpIfNotExists(npmCacheDirectory()); err != nil {
+		return err
 	}
 
-	builder.SetEnv("HOME", "/home/node/usr")
-	builder.SetUser("1001")
-	builder.SetWorkDir("/home/node/src")
+	installDir := path.Join(targetDirectory, "vendor")
+	if err := util.MkdirpIfNotExists(installDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(installDir)
 
-	builder.SetCmd([]string{"node", "/home/node/src/index.js"})
+	for _, f := range packageFiles {
+		if util.FileExist(path.Join(targetDirectory, "usr"), f) {
+			if err := util.Copy(path.Join(targetDirectory, "usr", f), path.Join(installDir, f)); err != nil {
+				return err
+			}
+		}
+	}
 
-	return util.CommitImage(builder, systemContext, imageName, imageTag)
+	return util.RunInContainer(
+		ctx,
+		config.BuildSystemContext,
+		baseImage,
+		[]util.BuildMount{
+			{Source: installDir, Destination: "/vendor"},
+			{Source: npmCacheDirectory(), Destination: npmCacheMount},
+		},
+		util.BuildCommand{Command: "npm install --no-audit --cache " + npmCacheMount, Wd: "/vendor"},
+	)
 }
 
 // DownloadRuntimeIfRequired is not used in the Node.js runtime
-func (j jsLanguageManager) DownloadRuntimeIfRequired() error {
+func (j jsLanguageManager) DownloadRuntimeIfRequired(ctx context.Context) error {
 	return nil
 }
 
-func (r jsLanguageManager) ConfigureEditingDirectory(mainFile string, functionConfiguration map[string][]string, editingDirectory string) (string, error) {
-	functionFile := path.Join(editingDirectory, "index.js")
-	err := util.Link(mainFile, functionFile)
+func (r jsLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
+	var err error
+	if function.IsDirectory() {
+		err = function.LinkSources(editingDirectory, "/package.json", "/index.js")
+		if err == nil {
+			err = writeEntryModule(editingDirectory, function.Entry())
+		}
+	} else {
+		err = util.Link(function.MainFile, path.Join(editingDirectory, "index.js"))
+	}
 	if err != nil {
 		return "", err
 	}
@@ -113,12 +189,24 @@ func (r jsLanguageManager) ConfigureEditingDirectory(mainFile string, functionCo
 	return editingDirectory, nil
 }
 
-func (j jsLanguageManager) ConfigureTargetDirectory(mainFile string, functionConfiguration map[string][]string, targetDirectory string) error {
-	if err := util.MkdirpIfNotExists(path.Join(targetDirectory, "usr")); err != nil {
+func (j jsLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	usrDir := path.Join(targetDirectory, "usr")
+	if err := util.CleanDirectory(usrDir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path.Join(targetDirectory, "src")); err != nil {
 		return err
 	}
 
-	err := util.Copy(mainFile, path.Join(targetDirectory, "usr", "index.js"))
+	var err error
+	if function.IsDirectory() {
+		err = function.CopySources(usrDir, "/package.json", "node_modules/")
+		if err == nil {
+			err = writeEntryModule(usrDir, function.Entry())
+		}
+	} else {
+		err = util.Copy(function.MainFile, path.Join(usrDir, "index.js"))
+	}
 	if err != nil {
 		return err
 	}
@@ -136,30 +224,71 @@ func (j jsLanguageManager) ConfigureTargetDirectory(mainFile string, functionCon
 	return util.Copy(runtimeDirectory(), path.Join(targetDirectory, "src"))
 }
 
+// The runtime loads index.js, so when the entry of a multi-file function has a different name
+// index.js re-exports it
+func writeEntryModule(directory string, entry string) error {
+	if entry == "index.js" {
+		return nil
+	}
+
+	module := fmt.Sprintf("module.exports = require(\"./%s\");\n", filepath.ToSlash(entry))
+	return util.WriteFiles(directory, util.WriteDest{Filename: "index.js", Data: []byte(module)})
+}
+
+func (j jsLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddString(baseImage)
+	if err := fingerprint.AddTree(runtimeDirectory(), util.IgnoreMatcher{}); err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
 func runtimeDirectory() string {
 	return path.Join(config.RuntimeDir, "js")
 }
 
-func generatePackageJson(configurationEntries map[string][]string) ([]byte, error) {
+func npmCacheDirectory() string {
+	return path.Join(config.VendorDir, "npm")
+}
+
+func checkNpmCache() error {
+	if !util.FsExist(npmCacheDirectory()) {
+		return fmt.Errorf("no vendored npm packages in %s. Run kfn vendor on a connected machine and import the vendor archive", npmCacheDirectory())
+	}
+	return nil
+}
+
+func generatePackageJson(configuration languages.Configuration) ([]byte, error) {
+	depsRoot, err := parseDependencies(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalPackageJson(depsRoot, nil)
+}
+
+func marshalPackageJson(dependencies map[string]string, devDependencies map[string]string) ([]byte, error) {
 	root := make(map[string]interface{})
 
 	root["name"] = "function"
 	root["version"] = "0.0.1"
 	root["description"] = ""
 
+	root["dependencies"] = dependencies
+	if devDependencies != nil {
+		root["devDependencies"] = devDependencies
+	}
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func parseDependencies(configuration languages.Configuration) (map[string]string, error) {
 	depsRoot := make(map[string]string)
 
-	if deps, ok := configurationEntries[util.DEPENDENCY]; ok {
-		for _, dep := range deps {
-			splitted := strings.Split(dep, " ")
-			if len(splitted) != 2 {
-				return nil, fmt.Errorf("Invalid dependency entry: %v", dep)
-			}
-			depsRoot[strings.Trim(splitted[0], " ")] = strings.Trim(splitted[1], " ")
-		}
+	for _, dep := range configuration.Dependencies() {
+		depsRoot[dep.Name] = dep.Version
 	}
 
-	root["dependencies"] = depsRoot
-
-	return json.MarshalIndent(root, "", "  ")
+	return depsRoot, nil
 }
@@ -0,0 +1,318 @@
+package js
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+const (
+	typescriptVersion = "3.6.3"
+	typesNodeVersion  = "12.7.8"
+	typesPrefix       = "@types/"
+	// JSX is compiled to React.createElement calls, so the functions using it depend on React and its types
+	reactVersion      = "16.10.2"
+	typesReactVersion = "16.9.5"
+)
+
+var tsDescriptor = languages.Descriptor{
+	Name:         languages.TypeScript,
+	LongName:     "TypeScript",
+	Extensions:   []string{".ts", ".tsx"},
+	LineComment:  "//",
+	Interpreters: []string{"ts-node"},
+	Template:     "index.ts",
+	EntryFiles:   []string{"index.ts", "index.tsx"},
+}
+
+// Files generated in the compilation directory, which must not be taken from multi-file functions
+var tsGeneratedFiles = []string{"/package.json", "/tsconfig.json", "/context.d.ts", "node_modules/"}
+
+// tsLanguageManager transpiles the function to Javascript and then builds the image like the Javascript manager
+type tsLanguageManager struct {
+	jsLanguageManager
+}
+
+func NewTsLanguageManager() languages.LanguageManager {
+	return tsLanguageManager{jsLanguageManager{util.NewResourceLoader("../../templates/ts")}}
+}
+
+func (t tsLanguageManager) Descriptor() languages.Descriptor {
+	return tsDescriptor
+}
+
+func (t tsLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
+	err := util.MkdirpIfNotExists(targetDirectory)
+	if err != nil {
+		return err
+	}
+
+	main, err := t.resourceLoader.LoadResource(tsDescriptor.Template)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(
+		targetDirectory,
+		util.WriteDest{Filename: functionName + tsDescriptor.Extensions[0], Data: main},
+	)
+}
+
+// The Typescript compiler is installed through npm, so only node and npm are required
+func (t tsLanguageManager) CheckCompileDependencies() error {
+	return util.CommandsExists("node", "npm", "npx")
+}
+
+func (t tsLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
+	var err error
+	if function.IsDirectory() {
+		err = function.LinkSources(editingDirectory, tsGeneratedFiles...)
+	} else {
+		err = util.Link(function.MainFile, path.Join(editingDirectory, entryFileName(function.MainFile)))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	err = t.writeCompilationFiles(function, functionConfiguration, editingDirectory, "out")
+	if err != nil {
+		return "", err
+	}
+
+	return editingDirectory, nil
+}
+
+// The target directory contains the `ts` directory with the sources to compile and the `usr` directory
+// with the Javascript output, like the Javascript manager
+func (t tsLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	tsDir := path.Join(targetDirectory, "ts")
+	usrDir := path.Join(targetDirectory, "usr")
+
+	// node_modules is kept, so the compilation dependencies are not installed again
+	if err := util.CleanDirectory(tsDir, "node_modules"); err != nil {
+		return err
+	}
+
+	// usr contains the compiler output, which would keep the modules of the deleted sources
+	if err := util.CleanDirectory(usrDir); err != nil {
+		return err
+	}
+
+	var err error
+	if function.IsDirectory() {
+		err = function.CopySources(tsDir, tsGeneratedFiles...)
+		if err == nil {
+			err = writeEntryModule(usrDir, compiledFileName(function.Entry()))
+		}
+	} else {
+		err = util.Copy(function.MainFile, path.Join(tsDir, entryFileName(function.MainFile)))
+	}
+	if err != nil {
+		return err
+	}
+
+	err = t.writeCompilationFiles(function, functionConfiguration, tsDir, usrDir)
+	if err != nil {
+		return err
+	}
+
+	dependencies, _, err := splitDependencies(functionConfiguration, usesJsx(function))
+	if err != nil {
+		return err
+	}
+
+	packageJson, err := marshalPackageJson(dependencies, nil)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(usrDir, util.WriteDest{Filename: "package.json", Data: packageJson})
+}
+
+func (t tsLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	install := []string{"npm", "install", "--no-audit"}
+	if config.Offline {
+		if err := checkNpmCache(); err != nil {
+			return "", nil, err
+		}
+		install = append(install, "--offline", "--cache", npmCacheDirectory())
+	}
+
+	commands := [][]string{
+		install,
+		{"npx", "--no-install", "tsc", "-p", "tsconfig.json"},
+	}
+	if err := runNpmCommands(ctx, path.Join(targetDirectory, "ts"), commands...); err != nil {
+		return "", nil, err
+	}
+
+	return path.Join(targetDirectory, "usr", "index.js"), []string{path.Join(targetDirectory, "usr", "package.json")}, nil
+}
+
+func runNpmCommands(ctx context.Context, dir string, commands ...[]string) error {
+	for _, c := range commands {
+		command := exec.Command(c[0], c[1:]...)
+		command.Dir = dir
+		command.Stdout = config.GetLoggerWriter()
+		command.Stderr = config.GetLoggerWriter()
+		command.Env = os.Environ()
+
+		if err := util.RunProcess(ctx, command); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("error occurred while running '%s'", strings.Join(c, " ")))
+		}
+	}
+	return nil
+}
+
+// Vendor caches the compilation dependencies, installed on the host, and then the ones installed in the image
+func (t tsLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	if err := util.MkdirpIfNotExists(npmCacheDirectory()); err != nil {
+		return err
+	}
+	err := runNpmCommands(ctx, path.Join(targetDirectory, "ts"), []string{"npm", "install", "--no-audit", "--cache", npmCacheDirectory()})
+	if err != nil {
+		return err
+	}
+	return t.jsLanguageManager.Vendor(ctx, function, functionConfiguration, targetDirectory)
+}
+
+func (t tsLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	jsFingerprint, err := t.jsLanguageManager.RuntimeFingerprint(ctx, functionConfiguration)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddString(jsFingerprint, typescriptVersion, typesNodeVersion, reactVersion, typesReactVersion)
+	if err := fingerprint.AddResources(t.resourceLoader, "context.d.ts"); err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
+// Writes package.json, tsconfig.json and the context type declaration required to compile the function
+func (t tsLanguageManager) writeCompilationFiles(function languages.Function, functionConfiguration languages.Configuration, directory string, outDir string) error {
+	dependencies, devDependencies, err := splitDependencies(functionConfiguration, usesJsx(function))
+	if err != nil {
+		return err
+	}
+
+	packageJson, err := marshalPackageJson(dependencies, devDependencies)
+	if err != nil {
+		return err
+	}
+
+	tsConfig, err := generateTsConfig(outDir)
+	if err != nil {
+		return err
+	}
+
+	contextTypes, err := t.resourceLoader.LoadResource("context.d.ts")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(
+		directory,
+		util.WriteDest{Filename: "package.json", Data: packageJson},
+		util.WriteDest{Filename: "tsconfig.json", Data: tsConfig},
+		util.WriteDest{Filename: "context.d.ts", Data: contextTypes},
+	)
+}
+
+// Type declarations (@types/...) are only required to compile, so they are added as devDependencies.
+// The functions using JSX get React and its types, unless they declare their own versions
+func splitDependencies(configuration languages.Configuration, jsx bool) (map[string]string, map[string]string, error) {
+	deps, err := parseDependencies(configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dependencies := make(map[string]string)
+	devDependencies := map[string]string{
+		"typescript":  typescriptVersion,
+		"@types/node": typesNodeVersion,
+	}
+	if jsx {
+		dependencies["react"] = reactVersion
+		devDependencies["@types/react"] = typesReactVersion
+	}
+
+	for name, version := range deps {
+		if strings.HasPrefix(name, typesPrefix) {
+			devDependencies[name] = version
+		} else {
+			dependencies[name] = version
+		}
+	}
+
+	return dependencies, devDependencies, nil
+}
+
+// usesJsx reports if the function has .tsx sources, looking for them in the directory of multi-file functions
+func usesJsx(function languages.Function) bool {
+	if path.Ext(function.MainFile) == ".tsx" {
+		return true
+	}
+	if !function.IsDirectory() {
+		return false
+	}
+
+	err := filepath.Walk(function.Directory, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && path.Ext(p) == ".tsx" {
+			return errJsxFound
+		}
+		return nil
+	})
+	return err == errJsxFound
+}
+
+// Stops the lookup of the .tsx sources at the first one
+var errJsxFound = errors.New("found a .tsx source")
+
+// The entry file is always named index, keeping the .tsx extension when the function uses JSX
+func entryFileName(mainFile string) string {
+	if path.Ext(mainFile) == ".tsx" {
+		return "index.tsx"
+	}
+	return "index.ts"
+}
+
+// The compiler keeps the directory layout, replacing the extension with .js
+func compiledFileName(source string) string {
+	return strings.TrimSuffix(source, path.Ext(source)) + ".js"
+}
+
+func generateTsConfig(outDir string) ([]byte, error) {
+	root := map[string]interface{}{
+		"compilerOptions": map[string]interface{}{
+			"target":          "es2017",
+			"module":          "commonjs",
+			"strict":          true,
+			"esModuleInterop": true,
+			"jsx":             "react",
+			"rootDir":         ".",
+			"outDir":          outDir,
+		},
+		"include": []string{"**/*.ts", "**/*.tsx"},
+	}
+
+	return json.MarshalIndent(root, "", "  ")
+}
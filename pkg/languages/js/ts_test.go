@@ -0,0 +1,66 @@
+package js
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+// JSX elements don't compile in strict mode without the React types
+func TestConfigureTargetDirectoryAddsReactForJsx(t *testing.T) {
+	functionDir, err := ioutil.TempDir("", "kfn-function")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(functionDir)
+	targetDir, err := ioutil.TempDir("", "kfn-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(targetDir)
+
+	err = util.WriteFiles(functionDir,
+		util.WriteDest{Filename: "index.ts", Data: []byte("export default (event: any) => event;\n")},
+		util.WriteDest{Filename: "card.tsx", Data: []byte("import * as React from 'react';\nexport const card = <div/>;\n")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	function, err := languages.ResolveFunction(functionDir, "index.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTsLanguageManager().ConfigureTargetDirectory(function, languages.NewConfiguration(), targetDir); err != nil {
+		t.Fatal(err)
+	}
+
+	var compilation struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	content, err := ioutil.ReadFile(path.Join(targetDir, "ts", "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &compilation); err != nil {
+		t.Fatal(err)
+	}
+	if compilation.DevDependencies["@types/react"] != typesReactVersion || compilation.Dependencies["react"] != reactVersion {
+		t.Errorf("expected react and its types in the compilation dependencies, got %s", content)
+	}
+}
+
+func TestUsesJsx(t *testing.T) {
+	if usesJsx(languages.Function{MainFile: "/functions/fn.ts"}) {
+		t.Error("expected a .ts function to not use JSX")
+	}
+	if !usesJsx(languages.Function{MainFile: "/functions/fn.tsx"}) {
+		t.Error("expected a .tsx function to use JSX")
+	}
+}
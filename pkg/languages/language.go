@@ -1,11 +1,21 @@
 package languages
 
 import (
-	"github.com/containers/image/types"
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
 	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
 )
 
-type Language uint8
+// Language is the name of a registered language, like `js` or `rust`
+type Language string
 
 func (l Language) Bootstrap(functionName string, targetDirectory string) error {
 	return ResolveLanguageManager(l).Bootstrap(functionName, targetDirectory)
@@ -15,54 +25,240 @@ func (l Language) CheckCompileDependencies() error {
 	return ResolveLanguageManager(l).CheckCompileDependencies()
 }
 
-func (l Language) Compile(mainFile string, functionConfiguration map[string][]string, targetDirectory string) (string, []string, error) {
-	return ResolveLanguageManager(l).Compile(mainFile, functionConfiguration, targetDirectory)
+func (l Language) Compile(ctx context.Context, function Function, functionConfiguration Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	return ResolveLanguageManager(l).Compile(ctx, function, functionConfiguration, platform, targetDirectory)
+}
+
+func (l Language) DownloadRuntimeIfRequired(ctx context.Context) error {
+	return ResolveLanguageManager(l).DownloadRuntimeIfRequired(ctx)
+}
+
+func (l Language) ConfigureEditingDirectory(function Function, functionConfiguration Configuration, editingDirectory string) (string, error) {
+	return ResolveLanguageManager(l).ConfigureEditingDirectory(function, functionConfiguration, editingDirectory)
+}
+
+func (l Language) ConfigureTargetDirectory(function Function, functionConfiguration Configuration, targetDirectory string) error {
+	return ResolveLanguageManager(l).ConfigureTargetDirectory(function, functionConfiguration, targetDirectory)
 }
 
-func (l Language) DownloadRuntimeIfRequired() error {
-	return ResolveLanguageManager(l).DownloadRuntimeIfRequired()
+func (l Language) RuntimeFingerprint(ctx context.Context, functionConfiguration Configuration) (string, error) {
+	return ResolveLanguageManager(l).RuntimeFingerprint(ctx, functionConfiguration)
 }
 
-func (l Language) ConfigureEditingDirectory(mainFile string, functionConfiguration map[string][]string, editingDirectory string) (string, error) {
-	return ResolveLanguageManager(l).ConfigureEditingDirectory(mainFile, functionConfiguration, editingDirectory)
+func (l Language) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	return ResolveLanguageManager(l).ImageRecipe(ctx, mainExecutable, additionalFiles, targetDirectory)
 }
 
-func (l Language) ConfigureTargetDirectory(mainFile string, functionConfiguration map[string][]string, targetDirectory string) error {
-	return ResolveLanguageManager(l).ConfigureTargetDirectory(mainFile, functionConfiguration, targetDirectory)
+func (l Language) ResolvedDependencies(ctx context.Context, functionConfiguration Configuration, targetDirectory string) ([]sbom.Component, error) {
+	return ResolveLanguageManager(l).ResolvedDependencies(ctx, functionConfiguration, targetDirectory)
 }
 
-func (l Language) BuildImage(systemContext *types.SystemContext, imageName string, imageTag string, mainExecutable string, additionalFiles []string, targetDirectory string) (image.FunctionImage, error) {
-	return ResolveLanguageManager(l).BuildImage(systemContext, imageName, imageTag, mainExecutable, additionalFiles, targetDirectory)
+func (l Language) Vendor(ctx context.Context, function Function, functionConfiguration Configuration, targetDirectory string) error {
+	return ResolveLanguageManager(l).Vendor(ctx, function, functionConfiguration, targetDirectory)
 }
 
 const (
-	Javascript Language = iota
-	Rust
-	Unknown
+	Javascript Language = "js"
+	Rust       Language = "rust"
+	Python     Language = "python"
+	Go         Language = "go"
+	TypeScript Language = "ts"
+	Java       Language = "java"
+	Unknown    Language = ""
 )
 
+// Descriptor declares how kfn recognizes and bootstraps the functions of a language
+type Descriptor struct {
+	// Name of the language, used as key of the registry and as `kfn init` subcommand
+	Name Language
+	// Human readable name
+	LongName string
+	// File extensions including the dot. The first one is used for new files
+	Extensions []string
+	// Prefix of line comments, used to parse the kfn configuration comments
+	LineComment string
+	// Interpreters recognized in the shebang line, like `node` or `python3`
+	Interpreters []string
+	// Template resource used to bootstrap a new function
+	Template string
+	// Conventional names of the entry file of multi-file functions
+	EntryFiles []string
+	// Configuration keys specific to the language
+	ConfigKeys []ConfigKey
+}
+
 func GetExtension(language Language) string {
-	switch language {
-	case Javascript:
-		return "js"
-	case Rust:
-		return "rs"
-	default:
-		return ""
+	if d, ok := GetDescriptor(language); ok && len(d.Extensions) != 0 {
+		return strings.TrimPrefix(d.Extensions[0], ".")
 	}
+	return ""
 }
 
 func GetLineComment(language Language) string {
+	if d, ok := GetDescriptor(language); ok && d.LineComment != "" {
+		return d.LineComment
+	}
 	return "//"
 }
 
+// GetLanguage resolves the language from the file extension (including the dot)
 func GetLanguage(ext string) Language {
-	switch ext {
-	case ".js":
-		return Javascript
-	case ".rs":
-		return Rust
-	default:
+	if language := languageByExtension(ext); language != Unknown || !loadLanguages() {
+		return language
+	}
+	return languageByExtension(ext)
+}
+
+func languageByExtension(ext string) Language {
+	for _, d := range Descriptors() {
+		for _, e := range d.Extensions {
+			if e == ext {
+				return d.Name
+			}
+		}
+	}
+	return Unknown
+}
+
+// GetLanguageByName resolves a registered language by its name
+func GetLanguageByName(name string) Language {
+	if _, ok := GetDescriptor(Language(name)); ok {
+		return Language(name)
+	}
+	return Unknown
+}
+
+// ResolveLanguage returns the language overridden by the user if not empty, otherwise it detects it from the function file
+func ResolveLanguage(functionPath string, override string) (Language, error) {
+	if override != "" {
+		language := GetLanguageByName(override)
+		if language == Unknown {
+			return Unknown, fmt.Errorf("Unknown language %s", override)
+		}
+		return language, nil
+	}
+
+	return DetectLanguage(functionPath)
+}
+
+// DetectLanguage detects the function language looking in order at:
+// 1. An explicit `kfn:language <name>` comment
+// 2. The file extension
+// 3. The interpreter in the shebang line
+func DetectLanguage(functionPath string) (Language, error) {
+	header, err := readHeader(functionPath)
+	if err != nil {
+		return Unknown, err
+	}
+
+	if name := languageFromComment(header); name != "" {
+		language := GetLanguageByName(name)
+		if language == Unknown {
+			return Unknown, fmt.Errorf("Unknown language %s declared in %s", name, functionPath)
+		}
+		return language, nil
+	}
+
+	if language := GetLanguage(path.Ext(functionPath)); language != Unknown {
+		return language, nil
+	}
+
+	if len(header) != 0 {
+		if language := languageFromShebang(header[0]); language != Unknown {
+			return language, nil
+		}
+	}
+
+	return Unknown, fmt.Errorf("Unknown language for function %s, use --language to specify it", functionPath)
+}
+
+// Reads the first lines of the file, where the shebang and the language comment are expected
+func readHeader(functionPath string) ([]string, error) {
+	f, err := os.Open(functionPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 50 && scanner.Scan(); i++ {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+// The lazy languages are loaded only when a kfn:language comment uses a line comment of none of the registered languages
+func languageFromComment(lines []string) string {
+	if name := languageFromCommentOf(Descriptors(), lines); name != "" || !hasLanguageComment(lines) || !loadLanguages() {
+		return name
+	}
+	return languageFromCommentOf(Descriptors(), lines)
+}
+
+func hasLanguageComment(lines []string) bool {
+	for _, l := range lines {
+		if strings.Contains(l, "kfn:language") {
+			return true
+		}
+	}
+	return false
+}
+
+func languageFromCommentOf(descriptors []Descriptor, lines []string) string {
+	comments := make([]string, 0)
+	for _, d := range descriptors {
+		comments = append(comments, regexp.QuoteMeta(d.LineComment))
+	}
+	if len(comments) == 0 {
+		return ""
+	}
+
+	commentRegex := regexp.MustCompile(`^[[:space:]]*(?:` + strings.Join(comments, "|") + `)[[:space:]]*kfn:language[[:space:]]+([^[:space:]]+)`)
+	for _, l := range lines {
+		if submatch := commentRegex.FindStringSubmatch(l); submatch != nil {
+			return submatch[1]
+		}
+	}
+	return ""
+}
+
+func languageFromShebang(line string) Language {
+	if !strings.HasPrefix(line, "#!") {
+		return Unknown
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
 		return Unknown
 	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env flags like -S
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") {
+				interpreter = path.Base(f)
+				break
+			}
+		}
+	}
+
+	if language := languageByInterpreter(interpreter); language != Unknown || !loadLanguages() {
+		return language
+	}
+	return languageByInterpreter(interpreter)
+}
+
+func languageByInterpreter(interpreter string) Language {
+	for _, d := range Descriptors() {
+		for _, i := range d.Interpreters {
+			if i == interpreter {
+				return d.Name
+			}
+		}
+	}
+	return Unknown
 }
@@ -1,11 +1,20 @@
 package languages
 
 import (
-	"github.com/containers/image/types"
+	"context"
+	"fmt"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
 	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
 )
 
 type LanguageManager interface {
+	// Describe the language handled by this manager
+	Descriptor() Descriptor
+
 	// Bootstrap a new function
 	Bootstrap(functionName string, targetDirectory string) error
 
@@ -13,29 +22,125 @@ type LanguageManager interface {
 	CheckCompileDependencies() error
 
 	// Download the runtime required to build the function
-	DownloadRuntimeIfRequired() error
+	DownloadRuntimeIfRequired(ctx context.Context) error
+
+	// Configure a temp directory with symlinks required to edit the function
+	ConfigureEditingDirectory(function Function, functionConfiguration Configuration, editingDirectory string) (directory string, err error)
 
-	// Configure a temp directory with symlinks required to edit the file
-	ConfigureEditingDirectory(mainFile string, functionConfiguration map[string][]string, editingDirectory string) (directory string, err error)
+	// Configure target directory, copying all the function sources
+	ConfigureTargetDirectory(function Function, functionConfiguration Configuration, targetDirectory string) error
 
-	// Configure target directory
-	ConfigureTargetDirectory(mainFile string, functionConfiguration map[string][]string, targetDirectory string) error
+	// Compile the function for the platform, returns executable + additional files to copy
+	Compile(ctx context.Context, function Function, functionConfiguration Configuration, platform image.Platform, targetDirectory string) (mainExecutable string, additionalFiles []string, err error)
 
-	// Compile with Main input file, returns executable + additional files to copy
-	Compile(mainFile string, functionConfiguration map[string][]string, targetDirectory string) (mainExecutable string, additionalFiles []string, err error)
+	// Identify the runtime and the base image used to build the function, so kfn can detect when the function must be rebuilt
+	RuntimeFingerprint(ctx context.Context, functionConfiguration Configuration) (string, error)
 
-	// Build the container image
-	BuildImage(systemContext *types.SystemContext, imageName string, imageTag string, mainExecutable string, additionalFiles []string, targetDirectory string) (image.FunctionImage, error)
+	// Describe how to build the container image
+	ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error)
+
+	// List the dependencies bundled in the image, resolved by the compilation and the image build, to generate the SBOM
+	ResolvedDependencies(ctx context.Context, functionConfiguration Configuration, targetDirectory string) ([]sbom.Component, error)
+
+	// Download the dependencies of the function, configured in the target directory, to the vendor directory,
+	// so the function can be built offline
+	Vendor(ctx context.Context, function Function, functionConfiguration Configuration, targetDirectory string) error
 }
 
 var (
 	languageManagerMap = make(map[Language]LanguageManager, 0)
+	// Keeps the registration order, to list the languages in a stable order
+	registeredLanguages = make([]Language, 0)
+	// The concurrent builds look up the languages while the lazy ones are registered
+	registryLock sync.RWMutex
+
+	// Loads the languages registered lazily, like the plugins, the first time a lookup misses the registered ones
+	languageLoader    func() []LanguageManager
+	loadLanguagesOnce sync.Once
 )
 
-func RegisterLanguageManager(language Language, manager LanguageManager) {
-	languageManagerMap[language] = manager
+// RegisterLanguageManager registers the manager with the name declared in its descriptor.
+// A language can't be registered twice
+func RegisterLanguageManager(manager LanguageManager) error {
+	name := manager.Descriptor().Name
+	if name == Unknown {
+		return fmt.Errorf("Cannot register a language without name")
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := languageManagerMap[name]; ok {
+		return fmt.Errorf("Language %s is already registered", name)
+	}
+
+	languageManagerMap[name] = manager
+	registeredLanguages = append(registeredLanguages, name)
+	return nil
+}
+
+// SetLanguageLoader sets the function returning the languages registered lazily, so they are loaded only
+// when a lookup doesn't find the language between the registered ones. The languages already registered win
+func SetLanguageLoader(loader func() []LanguageManager) {
+	languageLoader = loader
+}
+
+// loadLanguages registers the lazy languages the first time it's called, returning true if the lookup must be retried
+func loadLanguages() bool {
+	if languageLoader == nil {
+		return false
+	}
+	loaded := false
+	loadLanguagesOnce.Do(func() {
+		for _, m := range languageLoader() {
+			if err := RegisterLanguageManager(m); err != nil {
+				log.Warnf("Skipping language %s: %v", m.Descriptor().Name, err)
+			}
+		}
+		loaded = true
+	})
+	return loaded
 }
 
 func ResolveLanguageManager(language Language) LanguageManager {
+	if manager := registeredManager(language); manager != nil || !loadLanguages() {
+		return manager
+	}
+	return registeredManager(language)
+}
+
+func GetDescriptor(language Language) (Descriptor, bool) {
+	manager := ResolveLanguageManager(language)
+	if manager == nil {
+		return Descriptor{}, false
+	}
+	return manager.Descriptor(), true
+}
+
+func registeredManager(language Language) LanguageManager {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
 	return languageManagerMap[language]
 }
+
+// Descriptors returns the descriptors of the registered languages, in registration order.
+// The lazy languages are included only if a previous lookup loaded them, use AllDescriptors to include them
+func Descriptors() []Descriptor {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	descriptors := make([]Descriptor, 0, len(registeredLanguages))
+	for _, l := range registeredLanguages {
+		descriptors = append(descriptors, languageManagerMap[l].Descriptor())
+	}
+	return descriptors
+}
+
+// AllDescriptors returns the descriptors of all the languages, loading the lazy ones
+func AllDescriptors() []Descriptor {
+	loadLanguages()
+	return Descriptors()
+}
+
+// VendorNotSupported is the error of the languages that can't be built offline
+func VendorNotSupported(descriptor Descriptor) error {
+	return fmt.Errorf("vendoring the dependencies of %s functions is not supported", descriptor.LongName)
+}
@@ -0,0 +1,27 @@
+package languages
+
+import "testing"
+
+func TestLanguageLoaderRunsOnlyOnMiss(t *testing.T) {
+	registerJavascript(t)
+
+	calls := 0
+	SetLanguageLoader(func() []LanguageManager {
+		calls++
+		return []LanguageManager{descriptorManager{descriptor: Descriptor{Name: "lua", Extensions: []string{".lua"}, LineComment: "--"}}}
+	})
+	defer SetLanguageLoader(nil)
+
+	if language := GetLanguage(".js"); language != Javascript || calls != 0 {
+		t.Errorf("expected the registered language without loading, got %s after %d loads", language, calls)
+	}
+	if language := Language(languageFromComment([]string{"// no language comment"})); language != "" || calls != 0 {
+		t.Errorf("expected no load without a language comment, got %s after %d loads", language, calls)
+	}
+	if language := GetLanguage(".lua"); language != "lua" || calls != 1 {
+		t.Errorf("expected the lazy language after a single load, got %s after %d loads", language, calls)
+	}
+	if language := GetLanguage(".unknown"); language != Unknown || calls != 1 {
+		t.Errorf("expected the languages to be loaded once, got %s after %d loads", language, calls)
+	}
+}
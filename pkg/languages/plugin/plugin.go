@@ -0,0 +1,370 @@
+// Package plugin implements languages provided by external executables.
+//
+// A language plugin is an executable named kfn-lang-<name>, placed in the kfn plugins directory
+// (~/.kfn/plugins) or in PATH. Kfn invokes it with the method name as only argument, writes the JSON
+// request on its stdin and reads the JSON response from its stdout. Every response can contain
+// an "error" field: when not empty, the invocation is considered failed.
+// Methods are:
+//
+//	describe                   {} -> {"name", "extensions", "lineComment", "interpreters", "configKeys"}
+//	bootstrap                  {"functionName", "targetDirectory"} -> {}
+//	checkCompileDependencies   {} -> {}
+//	downloadRuntimeIfRequired  {"runtimeDirectory"} -> {}
+//	configureEditingDirectory  {"mainFile", "directory", "configuration", "editingDirectory"} -> {"directory"}
+//	configureTargetDirectory   {"mainFile", "directory", "configuration", "targetDirectory"} -> {}
+//	compile                    {"mainFile", "directory", "configuration", "platform", "targetDirectory"} -> {"mainExecutable", "additionalFiles"}
+//	buildImage                 {"mainExecutable", "additionalFiles", "targetDirectory"} -> image recipe
+//
+// The image recipe describes the image to build: {"baseImage", "port", "user", "workDir", "env",
+// "cmd", "entrypoint", "add": [{"from", "to"}], "run": [{"command", "wd"}]}. Relative "from" paths are
+// resolved against the target directory. Kfn builds and pushes the image, or renders it to a Containerfile.
+//
+// The "directory" field of the requests is the root of multi-file functions and it's empty for single file
+// functions. Plugins are responsible of honoring the .kfnignore file when copying the function directory.
+//
+// "configKeys" declares the `kfn:` configuration keys specific to the language, like
+// [{"name": "build-dev", "type": "bool", "repeatable": false, "allowed": []}]. Types are string, bool, int,
+// env and dependency. "configuration" contains the validated `kfn:` comments of the function, grouped by key.
+//
+// "platform" is the target of the compilation, like {"os": "linux", "architecture": "arm64", "variant": ""}.
+// For multi-architecture builds, compile and buildImage are invoked once per platform.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+const (
+	executablePrefix = "kfn-lang-"
+)
+
+type pluginLanguageManager struct {
+	executable string
+	descriptor languages.Descriptor
+}
+
+func NewPluginLanguageManager(executable string, descriptor languages.Descriptor) languages.LanguageManager {
+	return pluginLanguageManager{executable: executable, descriptor: descriptor}
+}
+
+// Discover looks for plugin executables in pluginsDirectory and then in PATH. When two plugins have
+// the same name, the first one found wins
+func Discover(pluginsDirectory string) map[string]string {
+	found := make(map[string]string)
+
+	dirs := append([]string{pluginsDirectory}, filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		if dir == "" || !util.DirExist(dir) {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Debugf("Cannot read directory %s: %v", dir, err)
+			continue
+		}
+		for _, f := range files {
+			name := f.Name()
+			if !strings.HasPrefix(name, executablePrefix) || f.IsDir() || f.Mode()&0111 == 0 {
+				continue
+			}
+			name = strings.TrimPrefix(name, executablePrefix)
+			if _, ok := found[name]; !ok {
+				found[name] = path.Join(dir, f.Name())
+			}
+		}
+	}
+
+	return found
+}
+
+// Describe asks the plugin for the language name, file extensions and comment syntax
+func Describe(executable string) (languages.Descriptor, error) {
+	var response struct {
+		Name         string   `json:"name"`
+		Extensions   []string `json:"extensions"`
+		LineComment  string   `json:"lineComment"`
+		Interpreters []string `json:"interpreters"`
+		ConfigKeys   []struct {
+			Name       string   `json:"name"`
+			Type       string   `json:"type"`
+			Repeatable bool     `json:"repeatable"`
+			Allowed    []string `json:"allowed"`
+		} `json:"configKeys"`
+	}
+	err := invoke(context.Background(), executable, "describe", struct{}{}, &response)
+	if err != nil {
+		return languages.Descriptor{}, err
+	}
+	if response.Name == "" || len(response.Extensions) == 0 {
+		return languages.Descriptor{}, fmt.Errorf("plugin %s returned an invalid description", executable)
+	}
+
+	descriptor := languages.Descriptor{
+		Name:         languages.Language(response.Name),
+		LongName:     response.Name,
+		LineComment:  response.LineComment,
+		Interpreters: response.Interpreters,
+	}
+	if descriptor.LineComment == "" {
+		descriptor.LineComment = "//"
+	}
+	for _, e := range response.Extensions {
+		descriptor.Extensions = append(descriptor.Extensions, "."+strings.TrimPrefix(e, "."))
+	}
+	for _, k := range response.ConfigKeys {
+		valueType := languages.ValueType(k.Type)
+		if valueType == "" {
+			valueType = languages.StringValue
+		}
+		switch valueType {
+		case languages.StringValue, languages.BoolValue, languages.IntValue, languages.EnvValue, languages.DependencyValue:
+		default:
+			return languages.Descriptor{}, fmt.Errorf("plugin %s declared the configuration key %s with unknown type %s", executable, k.Name, k.Type)
+		}
+		descriptor.ConfigKeys = append(descriptor.ConfigKeys, languages.ConfigKey{
+			Name:       k.Name,
+			Type:       valueType,
+			Repeatable: k.Repeatable,
+			Allowed:    k.Allowed,
+		})
+	}
+	return descriptor, nil
+}
+
+func (p pluginLanguageManager) Descriptor() languages.Descriptor {
+	return p.descriptor
+}
+
+func (p pluginLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
+	if err := util.MkdirpIfNotExists(targetDirectory); err != nil {
+		return err
+	}
+
+	targetDirectory, err := filepath.Abs(targetDirectory)
+	if err != nil {
+		return err
+	}
+
+	return invoke(context.Background(), p.executable, "bootstrap", map[string]interface{}{
+		"functionName":    functionName,
+		"targetDirectory": targetDirectory,
+	}, nil)
+}
+
+func (p pluginLanguageManager) CheckCompileDependencies() error {
+	return invoke(context.Background(), p.executable, "checkCompileDependencies", struct{}{}, nil)
+}
+
+// The runtime directory is reserved to the plugin, so it can cache the downloaded runtime across builds
+func (p pluginLanguageManager) DownloadRuntimeIfRequired(ctx context.Context) error {
+	runtimeDirectory := path.Join(config.RuntimeDir, "plugin-"+string(p.descriptor.Name))
+	if err := util.MkdirpIfNotExists(runtimeDirectory); err != nil {
+		return err
+	}
+
+	return invoke(ctx, p.executable, "downloadRuntimeIfRequired", map[string]interface{}{
+		"runtimeDirectory": runtimeDirectory,
+	}, nil)
+}
+
+func (p pluginLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
+	var response struct {
+		Directory string `json:"directory"`
+	}
+	err := invoke(context.Background(), p.executable, "configureEditingDirectory", map[string]interface{}{
+		"mainFile":         function.MainFile,
+		"directory":        function.Directory,
+		"configuration":    functionConfiguration.ToMap(),
+		"editingDirectory": editingDirectory,
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+
+	if response.Directory == "" {
+		return editingDirectory, nil
+	}
+	return response.Directory, nil
+}
+
+func (p pluginLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	return invoke(context.Background(), p.executable, "configureTargetDirectory", map[string]interface{}{
+		"mainFile":        function.MainFile,
+		"directory":       function.Directory,
+		"configuration":   functionConfiguration.ToMap(),
+		"targetDirectory": targetDirectory,
+	}, nil)
+}
+
+func (p pluginLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	var response struct {
+		MainExecutable  string   `json:"mainExecutable"`
+		AdditionalFiles []string `json:"additionalFiles"`
+	}
+	err := invoke(ctx, p.executable, "compile", map[string]interface{}{
+		"mainFile":        function.MainFile,
+		"directory":       function.Directory,
+		"configuration":   functionConfiguration.ToMap(),
+		"platform":        platformRequest(platform),
+		"targetDirectory": targetDirectory,
+	}, &response)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return response.MainExecutable, response.AdditionalFiles, nil
+}
+
+// The platform is sent as {"os", "architecture", "variant"}, with the default platform resolved
+func platformRequest(platform image.Platform) map[string]string {
+	return map[string]string{
+		"os":           platform.OSOrDefault(),
+		"architecture": platform.ArchitectureOrDefault(),
+		"variant":      platform.Variant,
+	}
+}
+
+// The plugins don't report the dependencies, so only the base image is listed in the SBOM
+func (p pluginLanguageManager) ResolvedDependencies(ctx context.Context, functionConfiguration languages.Configuration, targetDirectory string) ([]sbom.Component, error) {
+	return nil, nil
+}
+
+// The plugin protocol has no vendoring request
+func (p pluginLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	return languages.VendorNotSupported(p.descriptor)
+}
+
+// The plugin and the runtime it downloaded are part of the fingerprint, since kfn doesn't know the plugin base image
+func (p pluginLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	fingerprint := util.NewFingerprint()
+	if err := fingerprint.AddFile(p.executable); err != nil {
+		return "", err
+	}
+	if err := fingerprint.AddTree(path.Join(config.RuntimeDir, "plugin-"+string(p.descriptor.Name)), util.IgnoreMatcher{}); err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
+type imageRecipe struct {
+	BaseImage  string            `json:"baseImage"`
+	Port       string            `json:"port"`
+	User       string            `json:"user"`
+	WorkDir    string            `json:"workDir"`
+	Env        map[string]string `json:"env"`
+	Cmd        []string          `json:"cmd"`
+	Entrypoint []string          `json:"entrypoint"`
+	Add        []struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"add"`
+	Run []struct {
+		Command string `json:"command"`
+		Wd      string `json:"wd"`
+	} `json:"run"`
+}
+
+func (p pluginLanguageManager) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	var recipe imageRecipe
+	err := invoke(ctx, p.executable, "buildImage", map[string]interface{}{
+		"mainExecutable":  mainExecutable,
+		"additionalFiles": additionalFiles,
+		"targetDirectory": targetDirectory,
+	}, &recipe)
+	if err != nil {
+		return util.ImageRecipe{}, err
+	}
+
+	result := util.ImageRecipe{
+		BaseImage:  recipe.BaseImage,
+		Port:       recipe.Port,
+		User:       recipe.User,
+		WorkDir:    recipe.WorkDir,
+		Entrypoint: recipe.Entrypoint,
+		Cmd:        recipe.Cmd,
+	}
+
+	for _, add := range recipe.Add {
+		from := add.From
+		if !filepath.IsAbs(from) {
+			from = path.Join(targetDirectory, from)
+		}
+		result.Add = append(result.Add, util.BuildAdd{From: from, To: add.To})
+	}
+
+	for _, run := range recipe.Run {
+		result.Run = append(result.Run, util.BuildCommand{Command: run.Command, Wd: run.Wd})
+	}
+
+	// The env is a JSON object, sort it to keep the image reproducible
+	envNames := make([]string, 0, len(recipe.Env))
+	for k := range recipe.Env {
+		envNames = append(envNames, k)
+	}
+	sort.Strings(envNames)
+	for _, k := range envNames {
+		result.Env = append(result.Env, util.BuildEnv{Name: k, Value: recipe.Env[k]})
+	}
+
+	return result, nil
+}
+
+// invoke runs the plugin method, piping the JSON request to stdin and decoding the stdout into response
+func invoke(ctx context.Context, executable string, method string, request interface{}, response interface{}) error {
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Invoking plugin %s %s", executable, method)
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(executable, method)
+	cmd.Stdin = bytes.NewReader(requestBytes)
+	cmd.Stdout = &stdout
+	cmd.Stderr = config.GetLoggerWriter()
+	cmd.Env = append(os.Environ(), "KFN_DIR="+config.KfnDir)
+
+	runErr := util.RunProcess(ctx, cmd)
+
+	// Try to decode the error returned by the plugin first, since it's more meaningful than the exit code
+	var pluginError struct {
+		Error string `json:"error"`
+	}
+	if stdout.Len() != 0 {
+		if err := json.Unmarshal(stdout.Bytes(), &pluginError); err != nil {
+			return fmt.Errorf("plugin %s %s returned an invalid response: %v", executable, method, err)
+		}
+	}
+	if pluginError.Error != "" {
+		return fmt.Errorf("plugin %s %s failed: %s", executable, method, pluginError.Error)
+	}
+	if runErr == context.Canceled || runErr == context.DeadlineExceeded {
+		return runErr
+	}
+	if runErr != nil {
+		return fmt.Errorf("plugin %s %s failed: %v", executable, method, runErr)
+	}
+
+	if response != nil && stdout.Len() != 0 {
+		return json.Unmarshal(stdout.Bytes(), response)
+	}
+	return nil
+}
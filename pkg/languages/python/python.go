@@ -0,0 +1,226 @@
+package python
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+const (
+	baseImage = "python:3.7-slim"
+)
+
+var descriptor = languages.Descriptor{
+	Name:         languages.Python,
+	LongName:     "Python",
+	Extensions:   []string{".py"},
+	LineComment:  "#",
+	Interpreters: []string{"python", "python3"},
+	Template:     "function.py",
+	EntryFiles:   []string{"function.py", "main.py"},
+}
+
+// The runtime loads function.py, so when the entry of a multi-file function has a different name
+// function.py loads it
+const entryModuleTemplate = `import os
+import runpy
+import sys
+
+_entry = os.path.join(os.path.dirname(os.path.abspath(__file__)), %q)
+sys.path.insert(0, os.path.dirname(_entry))
+function = runpy.run_path(_entry)["function"]
+`
+
+type pythonLanguageManager struct {
+	resourceLoader util.ResourceLoader
+}
+
+func NewPythonLanguageManager() languages.LanguageManager {
+	return pythonLanguageManager{util.NewResourceLoader("../../templates/python")}
+}
+
+func (p pythonLanguageManager) Descriptor() languages.Descriptor {
+	return descriptor
+}
+
+func (p pythonLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
+	err := util.MkdirpIfNotExists(targetDirectory)
+	if err != nil {
+		return err
+	}
+
+	main, err := p.resourceLoader.LoadResource(descriptor.Template)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(
+		targetDirectory,
+		util.WriteDest{Filename: functionName + descriptor.Extensions[0], Data: main},
+	)
+}
+
+// For Python nothing is required, since dependencies are installed directly in the container
+func (p pythonLanguageManager) CheckCompileDependencies() error {
+	return nil
+}
+
+// DownloadRuntimeIfRequired is not used in the Python runtime, since it's shipped within kfn
+func (p pythonLanguageManager) DownloadRuntimeIfRequired(ctx context.Context) error {
+	return nil
+}
+
+func (p pythonLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
+	var err error
+	if function.IsDirectory() {
+		err = function.LinkSources(editingDirectory, generatedFiles(function)...)
+		if err == nil {
+			err = writeEntryModule(editingDirectory, function.Entry())
+		}
+	} else {
+		err = util.Link(function.MainFile, path.Join(editingDirectory, "function.py"))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	requirements, err := generateRequirementsTxt(functionConfiguration)
+	if err != nil {
+		return "", err
+	}
+
+	err = util.WriteFiles(editingDirectory, util.WriteDest{Filename: "requirements.txt", Data: requirements})
+	if err != nil {
+		return "", err
+	}
+
+	return editingDirectory, nil
+}
+
+func (p pythonLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	if err := util.CleanDirectory(path.Join(targetDirectory, "usr")); err != nil {
+		return err
+	}
+
+	if err := util.CleanDirectory(path.Join(targetDirectory, "src")); err != nil {
+		return err
+	}
+
+	var err error
+	if function.IsDirectory() {
+		err = function.CopySources(path.Join(targetDirectory, "usr"), append(generatedFiles(function), "__pycache__/")...)
+		if err == nil {
+			err = writeEntryModule(path.Join(targetDirectory, "usr"), function.Entry())
+		}
+	} else {
+		err = util.Copy(function.MainFile, path.Join(targetDirectory, "usr", "function.py"))
+	}
+	if err != nil {
+		return err
+	}
+
+	requirements, err := generateRequirementsTxt(functionConfiguration)
+	if err != nil {
+		return err
+	}
+
+	err = util.WriteFiles(path.Join(targetDirectory, "usr"), util.WriteDest{Filename: "requirements.txt", Data: requirements})
+	if err != nil {
+		return err
+	}
+
+	runtime, err := p.resourceLoader.LoadResource("runtime.py")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFiles(path.Join(targetDirectory, "src"), util.WriteDest{Filename: "runtime.py", Data: runtime})
+}
+
+// Python is not compiled, the dependencies are installed while building the image
+func (p pythonLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	return path.Join(targetDirectory, "usr", "function.py"), []string{path.Join(targetDirectory, "usr", "requirements.txt")}, nil
+}
+
+// pip doesn't produce a lockfile, so only the base image is listed in the SBOM
+func (p pythonLanguageManager) ResolvedDependencies(ctx context.Context, functionConfiguration languages.Configuration, targetDirectory string) ([]sbom.Component, error) {
+	return nil, nil
+}
+
+// The python dependencies are installed by pip in the image, vendoring them is not implemented yet
+func (p pythonLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	return languages.VendorNotSupported(descriptor)
+}
+
+func (p pythonLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddString(baseImage)
+	if err := fingerprint.AddResources(p.resourceLoader, "runtime.py"); err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
+func (p pythonLanguageManager) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	return util.ImageRecipe{
+		BaseImage: baseImage,
+		Port:      "8080",
+		Add: []util.BuildAdd{
+			{From: path.Join(targetDirectory, "usr"), To: "/home/app/usr"},
+			{From: path.Join(targetDirectory, "src"), To: "/home/app/src"},
+		},
+		Run: []util.BuildCommand{{Command: "pip install --no-cache-dir -r requirements.txt", Wd: "/home/app/usr"}},
+		Env: []util.BuildEnv{
+			{Name: "HOME", Value: "/home/app/usr"},
+			{Name: "PYTHONUNBUFFERED", Value: "1"},
+		},
+		User:    "1001",
+		WorkDir: "/home/app/usr",
+		Cmd:     []string{"python3", "/home/app/src/runtime.py"},
+	}, nil
+}
+
+// Every dependency entry is in the form `name version`, where version can be `latest`,
+// a plain version (pinned with ==) or a pip version specifier like `>=1.0`
+func generateRequirementsTxt(configuration languages.Configuration) ([]byte, error) {
+	var requirements strings.Builder
+
+	for _, dep := range configuration.Dependencies() {
+		switch {
+		case dep.Version == "latest" || dep.Version == "*":
+			requirements.WriteString(dep.Name)
+		case strings.IndexAny(dep.Version, "=<>!~") == 0:
+			requirements.WriteString(dep.Name + dep.Version)
+		default:
+			requirements.WriteString(dep.Name + "==" + dep.Version)
+		}
+		requirements.WriteString("\n")
+	}
+
+	return []byte(requirements.String()), nil
+}
+
+// generatedFiles are the files kfn writes next to the function sources, which must not be taken from the function directory.
+// function.py is generated only when the entry has another name
+func generatedFiles(function languages.Function) []string {
+	if function.Entry() == "function.py" {
+		return []string{"/requirements.txt"}
+	}
+	return []string{"/requirements.txt", "/function.py"}
+}
+
+func writeEntryModule(directory string, entry string) error {
+	if entry == "function.py" {
+		return nil
+	}
+
+	module := fmt.Sprintf(entryModuleTemplate, filepath.ToSlash(entry))
+	return util.WriteFiles(directory, util.WriteDest{Filename: "function.py", Data: []byte(module)})
+}
@@ -0,0 +1,58 @@
+package python
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+func TestConfigureTargetDirectoryRemovesDeletedSources(t *testing.T) {
+	functionDir, err := ioutil.TempDir("", "kfn-function")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(functionDir)
+	targetDir, err := ioutil.TempDir("", "kfn-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(targetDir)
+
+	err = util.WriteFiles(functionDir,
+		util.WriteDest{Filename: "function.py", Data: []byte("import helper\n\ndef function(event):\n    return helper.answer()\n")},
+		util.WriteDest{Filename: "helper.py", Data: []byte("def answer():\n    return 42\n")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	function, err := languages.ResolveFunction(functionDir, "function.py")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := NewPythonLanguageManager()
+
+	if err := manager.ConfigureTargetDirectory(function, languages.NewConfiguration(), targetDir); err != nil {
+		t.Fatal(err)
+	}
+	if !util.FileExist(targetDir, "usr", "helper.py") {
+		t.Fatal("helper.py was not copied in the target directory")
+	}
+
+	if err := os.Remove(path.Join(functionDir, "helper.py")); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.ConfigureTargetDirectory(function, languages.NewConfiguration(), targetDir); err != nil {
+		t.Fatal(err)
+	}
+	if util.FileExist(targetDir, "usr", "helper.py") {
+		t.Error("helper.py was deleted from the function but it's still in the target directory")
+	}
+	if !util.FileExist(targetDir, "usr", "function.py") {
+		t.Error("function.py is missing from the target directory")
+	}
+}
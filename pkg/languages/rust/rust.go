@@ -7,10 +7,8 @@ import (
 	"os"
 	"os/exec"
 	"path"
-	"strconv"
 	"strings"
 
-	"github.com/containers/image/types"
 	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -18,6 +16,7 @@ import (
 	"github.com/slinkydeveloper/kfn/pkg/config"
 	"github.com/slinkydeveloper/kfn/pkg/image"
 	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
 	"github.com/slinkydeveloper/kfn/pkg/util"
 )
 
@@ -25,33 +24,75 @@ const (
 	rustRuntimeRemoteZip = "https://github.com/openshift-cloud-functions/faas-rust-runtime/archive/master.zip"
 	buildEnvVariables    = "build-env"
 	buildDevProfile      = "build-dev"
+	buildTarget          = "target"
+	muslTarget           = "x86_64-unknown-linux-musl"
+	wasiTarget           = "wasm32-wasi"
+	// The WASI host serves http on 8080 and, for every request, runs the module
+	// at /function.wasm passing the event through stdin and replying with stdout.
+	// It's built from runtimes/wasi with make wasi-runtime
+	wasiHostImage = "oscf/wasi-runtime:0.0.1"
+	// CARGO_HOME of the builder image
+	builderCargoHome = "/root/.cargo"
+	// The vendored crates are mounted in the builder container at this path
+	builderVendorDir = "/kfn-vendor/cargo"
 )
 
+// Musl targets of the supported architectures
+var muslTargets = map[string]string{
+	"amd64":  muslTarget,
+	"arm64":  "aarch64-unknown-linux-musl",
+	"arm/v7": "armv7-unknown-linux-musleabihf",
+}
+
+// Builder images cross compiling to the other musl targets, with the linker already configured
+var crossBuilderImages = map[string]string{
+	"aarch64-unknown-linux-musl":     "messense/rust-musl-cross:aarch64-musl",
+	"armv7-unknown-linux-musleabihf": "messense/rust-musl-cross:armv7-musleabihf",
+}
+
+var descriptor = languages.Descriptor{
+	Name:        languages.Rust,
+	LongName:    "Rust",
+	Extensions:  []string{".rs"},
+	LineComment: "//",
+	Template:    "function.rs",
+	ConfigKeys: []languages.ConfigKey{
+		{Name: buildDevProfile, Type: languages.BoolValue, Description: "Build with the cargo dev profile"},
+		{Name: buildTarget, Type: languages.StringValue, Allowed: []string{muslTarget, wasiTarget}, Description: "Compile target"},
+		{Name: buildEnvVariables, Type: languages.EnvValue, Repeatable: true, Description: "Environment variable for cargo build"},
+	},
+	EntryFiles: []string{"function.rs", "lib.rs"},
+}
+
 type rustLanguageManager struct {
 	resourceLoader util.ResourceLoader
 }
 
+func (r rustLanguageManager) Descriptor() languages.Descriptor {
+	return descriptor
+}
+
 func (r rustLanguageManager) Bootstrap(functionName string, targetDirectory string) error {
 	err := util.MkdirpIfNotExists(targetDirectory)
 	if err != nil {
 		return err
 	}
 
-	main, err := r.resourceLoader.LoadResource("function.rs")
+	main, err := r.resourceLoader.LoadResource(descriptor.Template)
 	if err != nil {
 		return err
 	}
 
 	return util.WriteFiles(
 		targetDirectory,
-		util.WriteDest{fmt.Sprintf("%s.rs", functionName), main},
+		util.WriteDest{Filename: functionName + descriptor.Extensions[0], Data: main},
 	)
 }
 
-func (r rustLanguageManager) DownloadRuntimeIfRequired() error {
+func (r rustLanguageManager) DownloadRuntimeIfRequired(ctx context.Context) error {
 	if !util.FsExist(runtimeDirectory()) {
-		if err := util.MkdirpIfNotExists(runtimeDirectory()); err != nil {
-			return err
+		if config.Offline {
+			return fmt.Errorf("the Rust runtime is not in %s and kfn is offline. Run kfn vendor on a connected machine and import the vendor archive", runtimeDirectory())
 		}
 
 		tempDir, err := ioutil.TempDir("", "faas-rust-runtime")
@@ -61,7 +102,7 @@ func (r rustLanguageManager) DownloadRuntimeIfRequired() error {
 
 		runtimeZip := path.Join(tempDir, "master.zip")
 
-		if err := util.DownloadFile(rustRuntimeRemoteZip, runtimeZip); err != nil {
+		if err := util.DownloadFile(ctx, rustRuntimeRemoteZip, runtimeZip); err != nil {
 			return err
 		}
 
@@ -73,6 +114,11 @@ func (r rustLanguageManager) DownloadRuntimeIfRequired() error {
 
 		logrus.Infof("Runtime unzipped to %s", tempDir)
 
+		// The runtime directory is created only after the download, so a failed download is retried by the next build
+		if err := util.MkdirpIfNotExists(runtimeDirectory()); err != nil {
+			return err
+		}
+
 		if err := util.Copy(path.Join(tempDir, "faas-rust-runtime-master", "src"), path.Join(runtimeDirectory(), "src")); err != nil {
 			return err
 		}
@@ -89,14 +135,28 @@ func (r rustLanguageManager) DownloadRuntimeIfRequired() error {
 }
 
 func (r rustLanguageManager) CheckCompileDependencies() error {
+	// The builder image provides the toolchain
+	if !config.RustHostBuild {
+		return nil
+	}
 	// musl-gcc is required for static linking the libc
 	return util.CommandsExists("rustc", "cargo", "musl-gcc")
 }
 
-func (r rustLanguageManager) ConfigureEditingDirectory(mainFile string, functionConfiguration map[string][]string, editingDirectory string) (string, error) {
+func (r rustLanguageManager) ConfigureEditingDirectory(function languages.Function, functionConfiguration languages.Configuration, editingDirectory string) (string, error) {
 	functionFile := path.Join(editingDirectory, "lib.rs")
 
-	cargoToml, err := generateCargoToml(functionConfiguration)
+	if function.IsDirectory() {
+		if err := checkEntryAtRoot(function); err != nil {
+			return "", err
+		}
+		if err := function.LinkSources(editingDirectory, "/Cargo.toml", "/lib.rs", "/"+function.Entry(), "target/"); err != nil {
+			return "", err
+		}
+	}
+
+	// The editing directory always uses the http runtime dependencies, which provides the same api of the WASI shim
+	cargoToml, err := generateCargoToml(functionConfiguration, false)
 	if err != nil {
 		return "", err
 	}
@@ -106,24 +166,49 @@ func (r rustLanguageManager) ConfigureEditingDirectory(mainFile string, function
 		return "", err
 	}
 
-	err = util.Link(mainFile, functionFile)
+	err = util.Link(function.MainFile, functionFile)
 	if err != nil {
 		return "", err
 	}
 
 	return editingDirectory, nil
 }
-func (r rustLanguageManager) ConfigureTargetDirectory(mainFile string, functionConfiguration map[string][]string, targetDirectory string) error {
-	if err := util.MkdirpIfNotExists(path.Join(targetDirectory, "function")); err != nil {
+
+// The entry of a multi-file function becomes lib.rs, so it must be in the function directory root
+// to keep the module paths unchanged
+func checkEntryAtRoot(function languages.Function) error {
+	if path.Dir(function.Entry()) != "." {
+		return fmt.Errorf("the entry file %s must be in the root of the function directory", function.Entry())
+	}
+	return nil
+}
+
+func (r rustLanguageManager) ConfigureTargetDirectory(function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	target, err := compileTarget(functionConfiguration)
+	if err != nil {
 		return err
 	}
 
-	err := util.Copy(mainFile, path.Join(targetDirectory, "function", "lib.rs"))
+	if err := util.CleanDirectory(path.Join(targetDirectory, "function")); err != nil {
+		return err
+	}
+
+	if function.IsDirectory() {
+		if err := checkEntryAtRoot(function); err != nil {
+			return err
+		}
+		err = function.CopySources(path.Join(targetDirectory, "function"), "/Cargo.toml", "/lib.rs", "/"+function.Entry(), "target/")
+		if err != nil {
+			return err
+		}
+	}
+
+	err = util.Copy(function.MainFile, path.Join(targetDirectory, "function", "lib.rs"))
 	if err != nil {
 		return err
 	}
 
-	cargoToml, err := generateCargoToml(functionConfiguration)
+	cargoToml, err := generateCargoToml(functionConfiguration, target == wasiTarget)
 	if err != nil {
 		return err
 	}
@@ -133,78 +218,299 @@ func (r rustLanguageManager) ConfigureTargetDirectory(mainFile string, functionC
 		return err
 	}
 
+	if target == wasiTarget {
+		return r.writeWasiRuntime(path.Join(targetDirectory, "runtime-wasi"))
+	}
+
 	return util.CopyContent(runtimeDirectory(), path.Join(targetDirectory, "runtime"))
 }
 
-func (r rustLanguageManager) Compile(mainFile string, functionConfiguration map[string][]string, targetDirectory string) (string, []string, error) {
-	devMode := false
+// The WASI runtime reads the event from stdin and replaces actix-web with a shim exposing only the error type
+func (r rustLanguageManager) writeWasiRuntime(runtimeDir string) error {
+	if err := util.MkdirpIfNotExists(path.Join(runtimeDir, "actix-web", "src")); err != nil {
+		return err
+	}
 
-	// Check profile for building
-	dev, ok := functionConfiguration[buildDevProfile]
-	if ok {
-		var err error
-		devMode, err = strconv.ParseBool(dev[0])
+	resources := []string{"Cargo.toml", "main.rs", "actix-web/Cargo.toml", "actix-web/src/lib.rs"}
+	for _, res := range resources {
+		data, err := r.resourceLoader.LoadResource(path.Join("wasi", res))
 		if err != nil {
-			devMode = false
+			return err
+		}
+		if err := util.WriteFiles(runtimeDir, util.WriteDest{Filename: res, Data: data}); err != nil {
+			return err
 		}
 	}
 
+	return nil
+}
+
+func (r rustLanguageManager) Compile(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, platform image.Platform, targetDirectory string) (string, []string, error) {
+	devMode := functionConfiguration.Bool(buildDevProfile, false)
+
 	log.Printf("Using cargo dev profile: %v", devMode)
 
-	var compileCommand *exec.Cmd
+	target, err := compileTarget(functionConfiguration)
+	if err != nil {
+		return "", nil, err
+	}
+	target, err = platformTarget(target, platform)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The builder image ships only the musl target and rustup can't download the wasi one without network
+	if target == wasiTarget && config.Offline && !config.RustHostBuild {
+		return "", nil, fmt.Errorf("%s is not available offline in the builder image, build online or use --rust-host-build with the target installed", wasiTarget)
+	}
+
+	log.Printf("Using compile target: %s", target)
+
+	cargoArgs := []string{"cargo", "build", "--target", target}
+	if !devMode {
+		cargoArgs = append(cargoArgs, "--release")
+	}
+	if config.Offline {
+		cargoArgs = append(cargoArgs, "--offline")
+	}
+
+	// Root Cargo.toml is in runtime dir in runtime
+	runtimeDirName := runtimeDirectoryName(target)
+	runtimeDir := path.Join(targetDirectory, runtimeDirName)
+
+	vendorDir := builderVendorDir
+	if config.RustHostBuild {
+		vendorDir = cargoVendorDirectory()
+	}
+	if err := writeCargoConfig(runtimeDir, vendorDir); err != nil {
+		return "", nil, err
+	}
+
+	env := functionConfiguration.Strings(buildEnvVariables)
+	for _, e := range env {
+		log.Printf("Adding env variable to cargo build: %s", e)
+	}
+
+	if config.RustHostBuild {
+		err = compileOnHost(ctx, cargoArgs, runtimeDir, target, env)
+	} else {
+		err = compileInContainer(ctx, cargoArgs, targetDirectory, runtimeDirName, target, env)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	executable := "rust-faas"
+	if target == wasiTarget {
+		executable = "rust-faas.wasm"
+	}
+
 	if devMode {
-		compileCommand = exec.Command("cargo", "build", "--target", "x86_64-unknown-linux-musl")
+		return path.Join(runtimeDir, "target", target, "debug", executable), nil, nil
 	} else {
-		compileCommand = exec.Command("cargo", "build", "--release", "--target", "x86_64-unknown-linux-musl")
+		return path.Join(runtimeDir, "target", target, "release", executable), nil, nil
 	}
-	// Root Cargo.toml is in runtime dir in runtime
-	compileCommand.Dir = path.Join(targetDirectory, "runtime")
+}
+
+func runtimeDirectoryName(target string) string {
+	if target == wasiTarget {
+		return "runtime-wasi"
+	}
+	return "runtime"
+}
+
+// writeCargoConfig replaces crates.io with the vendored crates when kfn is offline, removing the replacement otherwise.
+// vendorDir is the path of the vendored crates seen by cargo
+func writeCargoConfig(runtimeDir string, vendorDir string) error {
+	cargoConfigDir := path.Join(runtimeDir, ".cargo")
+	if !config.Offline {
+		return os.RemoveAll(cargoConfigDir)
+	}
+
+	if !util.FsExist(cargoVendorDirectory()) {
+		return fmt.Errorf("no vendored crates in %s. Run kfn vendor on a connected machine and import the vendor archive", cargoVendorDirectory())
+	}
+	if err := util.MkdirpIfNotExists(cargoConfigDir); err != nil {
+		return err
+	}
+
+	cargoConfig := fmt.Sprintf(`[source.crates-io]
+replace-with = "vendored-sources"
+
+[source.vendored-sources]
+directory = "%s"
+`, vendorDir)
+	return util.WriteFiles(cargoConfigDir, util.WriteDest{Filename: "config", Data: []byte(cargoConfig)})
+}
+
+func compileOnHost(ctx context.Context, cargoArgs []string, runtimeDir string, target string, env []string) error {
+	compileCommand := exec.Command(cargoArgs[0], cargoArgs[1:]...)
+	compileCommand.Dir = runtimeDir
 	// Configure proper logging
 	compileCommand.Stdout = config.GetLoggerWriter()
 	compileCommand.Stderr = config.GetLoggerWriter()
-	compileCommand.Env = os.Environ()
+	compileCommand.Env = append(os.Environ(), env...)
 
-	envFlags, ok := functionConfiguration[buildEnvVariables]
-	if ok {
-		for _, env := range envFlags {
-			log.Printf("Adding env variable to cargo build: %s", env)
-			compileCommand.Env = append(compileCommand.Env, env)
+	err := util.RunProcess(ctx, compileCommand)
+	if err != nil {
+		if target == wasiTarget {
+			return errors.Wrap(err, "error occurred while trying to compile. Check if you installed the wasi rustc target with 'rustup target add wasm32-wasi'")
+		}
+		if target != muslTarget {
+			return errors.Wrap(err, fmt.Sprintf("error occurred while trying to compile. Check if you installed the rustc target with 'rustup target add %s' and configured its musl linker", target))
 		}
+		return errors.Wrap(err, "error occurred while trying to compile. Check if you installed correctly 'https://www.musl-libc.org/how.html' and musl rustc target with 'rustup target add x86_64-unknown-linux-musl'")
 	}
+	return nil
+}
 
-	err := compileCommand.Run()
+// compileInContainer runs cargo inside the builder image. The target directory is mounted, so the cargo target
+// directory is kept between the builds, while the cargo registry and git checkouts are cached in the kfn directory
+func compileInContainer(ctx context.Context, cargoArgs []string, targetDirectory string, runtimeDirName string, target string, env []string) error {
+	mounts, err := builderMounts(targetDirectory)
 	if err != nil {
-		return "", nil, errors.Wrap(err, "error occurred while trying to compile. Check if you installed correctly 'https://www.musl-libc.org/how.html' and musl rustc target with 'rustup target add x86_64-unknown-linux-musl'")
+		return err
 	}
 
-	if devMode {
-		return path.Join(targetDirectory, "runtime", "target", "x86_64-unknown-linux-musl", "debug", "rust-faas"), nil, nil
-	} else {
-		return path.Join(targetDirectory, "runtime", "target", "x86_64-unknown-linux-musl", "release", "rust-faas"), nil, nil
+	builderImage := config.RustBuilderImage
+	if crossImage, ok := crossBuilderImages[target]; ok {
+		builderImage = crossImage
+	}
+
+	log.Printf("Compiling inside %s", builderImage)
+
+	var commands []util.BuildCommand
+	if target == wasiTarget {
+		// The builder image ships only the musl target, rustup skips the download if the target is already installed
+		commands = append(commands, util.BuildCommand{Command: "rustup target add " + target})
+	}
+	commands = append(commands, util.BuildCommand{
+		Command: strings.Join(cargoArgs, " "),
+		Wd:      path.Join("/build", runtimeDirName),
+		Env:     env,
+	})
+
+	err = util.RunInContainer(ctx, config.BuildSystemContext, builderImage, mounts, commands...)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error occurred while compiling inside %s. Use --rust-host-build to compile with the host toolchain", builderImage))
+	}
+	return nil
+}
+
+// builderMounts mounts the target directory in /build, the cargo registry and git checkouts cached in the kfn directory
+// and the vendored crates
+func builderMounts(targetDirectory string) ([]util.BuildMount, error) {
+	cargoCache := path.Join(config.CacheDir, "cargo")
+	for _, dir := range []string{path.Join(cargoCache, "registry"), path.Join(cargoCache, "git"), cargoVendorDirectory()} {
+		if err := util.MkdirpIfNotExists(dir); err != nil {
+			return nil, err
+		}
 	}
+
+	return []util.BuildMount{
+		{Source: targetDirectory, Destination: "/build"},
+		{Source: path.Join(cargoCache, "registry"), Destination: path.Join(builderCargoHome, "registry")},
+		{Source: path.Join(cargoCache, "git"), Destination: path.Join(builderCargoHome, "git")},
+		{Source: cargoVendorDirectory(), Destination: builderVendorDir},
+	}, nil
 }
 
-func (r rustLanguageManager) BuildImage(systemContext *types.SystemContext, imageName string, imageTag string, mainExecutable string, additionalFiles []string, targetDirectory string) (image.FunctionImage, error) {
-	builder, err := util.InitializeBuilder(context.TODO(), systemContext, "")
+// Vendor runs cargo vendor in the runtime directory, adding the crates to the ones already vendored for the other functions
+func (r rustLanguageManager) Vendor(ctx context.Context, function languages.Function, functionConfiguration languages.Configuration, targetDirectory string) error {
+	target, err := compileTarget(functionConfiguration)
 	if err != nil {
-		return image.FunctionImage{}, err
+		return err
 	}
 
-	builder.SetPort("8080")
+	runtimeDirName := runtimeDirectoryName(target)
+	runtimeDir := path.Join(targetDirectory, runtimeDirName)
+	// cargo vendor must read the crates from crates.io
+	if err := os.RemoveAll(path.Join(runtimeDir, ".cargo")); err != nil {
+		return err
+	}
 
-	err = util.Add(
-		builder,
-		util.BuildAdd{From: mainExecutable, To: "/"},
-	)
+	vendorArgs := []string{"cargo", "vendor", "--versioned-dirs", "--no-delete"}
+
+	if config.RustHostBuild {
+		if err := util.MkdirpIfNotExists(cargoVendorDirectory()); err != nil {
+			return err
+		}
+		vendorArgs = append(vendorArgs, cargoVendorDirectory())
+		vendorCommand := exec.Command(vendorArgs[0], vendorArgs[1:]...)
+		vendorCommand.Dir = runtimeDir
+		vendorCommand.Stdout = config.GetLoggerWriter()
+		vendorCommand.Stderr = config.GetLoggerWriter()
+		return util.RunProcess(ctx, vendorCommand)
+	}
+
+	mounts, err := builderMounts(targetDirectory)
 	if err != nil {
-		return image.FunctionImage{}, err
+		return err
 	}
+	vendorArgs = append(vendorArgs, builderVendorDir)
+	return util.RunInContainer(ctx, config.BuildSystemContext, config.RustBuilderImage, mounts, util.BuildCommand{
+		Command: strings.Join(vendorArgs, " "),
+		Wd:      path.Join("/build", runtimeDirName),
+	})
+}
 
-	builder.SetUser("1000")
-	builder.SetCmd([]string{"/rust-faas"})
-	builder.SetEntrypoint([]string{})
+// The Cargo.lock is generated by cargo in the runtime directory while compiling
+func (r rustLanguageManager) ResolvedDependencies(ctx context.Context, functionConfiguration languages.Configuration, targetDirectory string) ([]sbom.Component, error) {
+	target, err := compileTarget(functionConfiguration)
+	if err != nil {
+		return nil, err
+	}
 
-	return util.CommitImage(builder, systemContext, imageName, imageTag)
+	cargoLock := path.Join(targetDirectory, runtimeDirectoryName(target), "Cargo.lock")
+	if !util.FsExist(cargoLock) {
+		return nil, nil
+	}
+	return sbom.ParseCargoLock(cargoLock)
+}
+
+func (r rustLanguageManager) RuntimeFingerprint(ctx context.Context, functionConfiguration languages.Configuration) (string, error) {
+	target, err := compileTarget(functionConfiguration)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint := util.NewFingerprint()
+	fingerprint.AddString(target)
+	// The version of the host toolchain is not tracked
+	if config.RustHostBuild {
+		fingerprint.AddString("host")
+	} else {
+		fingerprint.AddString(config.RustBuilderImage)
+	}
+	if target == wasiTarget {
+		fingerprint.AddString(wasiHostImage)
+		err = fingerprint.AddResources(r.resourceLoader, "wasi/Cargo.toml", "wasi/main.rs", "wasi/actix-web/Cargo.toml", "wasi/actix-web/src/lib.rs")
+	} else {
+		err = fingerprint.AddTree(runtimeDirectory(), util.IgnoreMatcher{})
+	}
+	if err != nil {
+		return "", err
+	}
+	return fingerprint.Sum(), nil
+}
+
+func (r rustLanguageManager) ImageRecipe(ctx context.Context, mainExecutable string, additionalFiles []string, targetDirectory string) (util.ImageRecipe, error) {
+	if path.Ext(mainExecutable) == ".wasm" {
+		return util.ImageRecipe{
+			BaseImage: wasiHostImage,
+			Port:      "8080",
+			Add:       []util.BuildAdd{{From: mainExecutable, To: "/function.wasm"}},
+			User:      "1000",
+		}, nil
+	}
+
+	return util.ImageRecipe{
+		Port:       "8080",
+		Add:        []util.BuildAdd{{From: mainExecutable, To: "/"}},
+		User:       "1000",
+		Entrypoint: []string{},
+		Cmd:        []string{"/rust-faas"},
+	}, nil
 }
 
 func NewRustLanguageManger() languages.LanguageManager {
@@ -215,40 +521,72 @@ func runtimeDirectory() string {
 	return path.Join(config.RuntimeDir, "rust")
 }
 
-type CargoFile struct {
-	Package      map[string]string `toml:"package"`
-	Lib          map[string]string `toml:"lib"`
-	Dependencies map[string]string `toml:"dependencies"`
+func cargoVendorDirectory() string {
+	return path.Join(config.VendorDir, "cargo")
+}
+
+func compileTarget(configuration languages.Configuration) (string, error) {
+	switch t := configuration.String(buildTarget, muslTarget); t {
+	case muslTarget, wasiTarget:
+		return t, nil
+	default:
+		return "", fmt.Errorf("Unsupported compile target %s, supported targets: %s, %s", t, muslTarget, wasiTarget)
+	}
+}
+
+// platformTarget returns the musl target triple of the platform architecture. WASM modules are portable,
+// so the wasi target doesn't change, but the wasi host image runs only on linux/amd64
+func platformTarget(target string, platform image.Platform) (string, error) {
+	if platform.IsDefault() {
+		return target, nil
+	}
+	if target == wasiTarget {
+		if platform.OS == "linux" && platform.Architecture == "amd64" && platform.Variant == "" {
+			return target, nil
+		}
+		return "", fmt.Errorf("platform %s is not supported by the %s target, its host image is available only for linux/amd64", platform, wasiTarget)
+	}
+
+	arch := platform.Architecture
+	if platform.Variant != "" {
+		arch += "/" + platform.Variant
+	}
+	if t, ok := muslTargets[arch]; ok && platform.OS == "linux" {
+		return t, nil
+	}
+	return "", fmt.Errorf("platform %s is not supported by Rust functions", platform)
 }
 
-func generateCargoToml(configurationEntries map[string][]string) ([]byte, error) {
-	deps := make(map[string]string)
-	deps["actix-web"] = "1.0.8"
+func generateCargoToml(configuration languages.Configuration, wasi bool) ([]byte, error) {
+	deps := make(map[string]interface{})
+	if wasi {
+		deps["actix-web"] = map[string]interface{}{"path": "../runtime-wasi/actix-web"}
+	} else {
+		deps["actix-web"] = "1.0.8"
+	}
 	deps["serde_json"] = "1.0"
 	deps["futures"] = "0.1.29"
 
-	if depConfigs, ok := configurationEntries[util.DEPENDENCY]; ok {
-		for _, dep := range depConfigs {
-			splitted := strings.Split(dep, " ")
-			if len(splitted) != 2 {
-				return nil, fmt.Errorf("Invalid dependency entry: %v", dep)
-			}
-			deps[strings.Trim(splitted[0], " ")] = strings.Trim(splitted[1], " ")
-		}
+	for _, dep := range configuration.Dependencies() {
+		deps[dep.Name] = dep.Version
 	}
 
-	root := CargoFile{
-		Package: map[string]string{
+	root, err := toml.TreeFromMap(map[string]interface{}{
+		"package": map[string]interface{}{
 			"name":    "function",
 			"version": "0.0.1",
 			"edition": "2018",
 		},
-		Lib: map[string]string{
+		"lib": map[string]interface{}{
 			"path": "lib.rs",
 		},
-		Dependencies: deps,
+		"dependencies": deps,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return toml.Marshal(root)
+	tomlString, err := root.ToTomlString()
+	return []byte(tomlString), err
 
 }
@@ -0,0 +1,78 @@
+package rust
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/languages"
+)
+
+// Without network rustup can't add the wasi target to the builder image, so the build must stop before starting it
+func TestCompileFailsOfflineWasiInBuilderImage(t *testing.T) {
+	offline, hostBuild := config.Offline, config.RustHostBuild
+	config.Offline, config.RustHostBuild = true, false
+	defer func() {
+		config.Offline, config.RustHostBuild = offline, hostBuild
+	}()
+
+	manager := NewRustLanguageManger()
+	if _, ok := languages.GetDescriptor(languages.Rust); !ok {
+		if err := languages.RegisterLanguageManager(manager); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	targetDir, err := ioutil.TempDir("", "kfn-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(targetDir)
+
+	functionFile := path.Join(targetDir, "function.rs")
+	if err := ioutil.WriteFile(functionFile, []byte("// kfn:target wasm32-wasi\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configuration, err := languages.ParseConfiguration(languages.Rust, functionFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = manager.Compile(context.Background(), languages.Function{MainFile: functionFile}, configuration, image.Platform{}, targetDir)
+	if err == nil || !strings.Contains(err.Error(), "wasm32-wasi is not available offline in the builder image") {
+		t.Errorf("expected the offline wasi error, got %v", err)
+	}
+}
+
+func TestPlatformTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		platform image.Platform
+		expected string
+		fails    bool
+	}{
+		{target: muslTarget, platform: image.Platform{}, expected: muslTarget},
+		{target: muslTarget, platform: image.Platform{OS: "linux", Architecture: "arm64"}, expected: muslTargets["arm64"]},
+		{target: wasiTarget, platform: image.Platform{}, expected: wasiTarget},
+		{target: wasiTarget, platform: image.Platform{OS: "linux", Architecture: "amd64"}, expected: wasiTarget},
+		{target: wasiTarget, platform: image.Platform{OS: "linux", Architecture: "arm64"}, fails: true},
+		{target: wasiTarget, platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, fails: true},
+	}
+	for _, test := range tests {
+		actual, err := platformTarget(test.target, test.platform)
+		if test.fails {
+			if err == nil {
+				t.Errorf("expected %s for %s to fail, got %s", test.target, test.platform, actual)
+			}
+			continue
+		}
+		if err != nil || actual != test.expected {
+			t.Errorf("expected %s for %s, got %s (%v)", test.expected, test.platform, actual, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package languages
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	sidecarExtension = ".kfn.yaml"
+	// Sidecar file of multi-file functions, placed in the function directory
+	sidecarFileName = "kfn.yaml"
+)
+
+// SidecarFile returns the path of the optional configuration file of the function:
+// <function>.kfn.yaml next to single file functions and kfn.yaml inside the function directory
+func SidecarFile(function Function) string {
+	if function.IsDirectory() {
+		return path.Join(function.Directory, sidecarFileName)
+	}
+	return strings.TrimSuffix(function.MainFile, path.Ext(function.MainFile)) + sidecarExtension
+}
+
+// LoadConfiguration parses the `kfn:` comments of the function and, if present, the sidecar file, then merges them.
+// The sidecar file wins: it replaces the values of the keys declared once, while the values of the repeatable keys
+// are appended to the comment ones. A dependency or an environment variable declared in both uses the sidecar value
+func LoadConfiguration(language Language, function Function) (Configuration, error) {
+	configuration, err := ParseConfiguration(language, function.MainFile)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	sidecar := SidecarFile(function)
+	if !util.FileExist(sidecar) {
+		return configuration, nil
+	}
+
+	sidecarConfiguration, err := ParseSidecarFile(language, sidecar)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	return configuration.Merge(sidecarConfiguration), nil
+}
+
+// ParseSidecarFile parses a yaml file mapping every key to a value or a list of values, like:
+//
+//	build-dev: true
+//	dependency:
+//	  - primal 0.2.3
+func ParseSidecarFile(language Language, sidecarFile string) (Configuration, error) {
+	schema := make(map[string]ConfigKey)
+	for _, k := range ConfigKeys(language) {
+		schema[k.Name] = k
+	}
+
+	content, err := ioutil.ReadFile(sidecarFile)
+	if err != nil {
+		return Configuration{}, err
+	}
+
+	var root yaml.MapSlice
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return Configuration{}, fmt.Errorf("%s: %v", sidecarFile, err)
+	}
+
+	configuration := NewConfiguration()
+	errs := make(ConfigErrors, 0)
+
+	for _, item := range root {
+		name := fmt.Sprint(item.Key)
+		key, ok := schema[name]
+		if !ok {
+			log.Warnf("%s: unknown configuration key %s", sidecarFile, name)
+			continue
+		}
+		if name == LanguageKey {
+			errs = append(errs, ConfigError{File: sidecarFile, Key: name, Message: "can be declared only in the function file"})
+			continue
+		}
+
+		values, ok := item.Value.([]interface{})
+		if !ok {
+			values = []interface{}{item.Value}
+		}
+
+		for _, v := range values {
+			entry := ConfigEntry{Key: name, File: sidecarFile}
+			err := entry.setSidecarValue(key, v)
+			if err == nil {
+				err = validateEntry(key, entry, configuration)
+			}
+			if err != nil {
+				errs = append(errs, ConfigError{File: sidecarFile, Key: name, Message: err.Error()})
+				continue
+			}
+
+			configuration.add(key, entry)
+		}
+	}
+
+	if len(errs) != 0 {
+		return Configuration{}, errs
+	}
+	return configuration, nil
+}
+
+// Yaml already handles the quoting, so only the dependencies and the trigger filters are split
+func (e *ConfigEntry) setSidecarValue(key ConfigKey, value interface{}) error {
+	switch value.(type) {
+	case nil, []interface{}, yaml.MapSlice, map[interface{}]interface{}:
+		return fmt.Errorf("expects a value of type %s or a list of them", key.Type)
+	}
+
+	if key.Type == DependencyValue || key.Type == TriggerValue {
+		args, err := splitArgs(fmt.Sprint(value))
+		if err != nil {
+			return err
+		}
+		e.Args = args
+		return nil
+	}
+
+	e.Args = []string{fmt.Sprint(value)}
+	return nil
+}
+
+// Merge returns a new configuration with the entries of other applied on top of the entries of c
+func (c Configuration) Merge(other Configuration) Configuration {
+	merged := NewConfiguration()
+	for k, entries := range c.entries {
+		merged.keys[k] = c.keys[k]
+		merged.entries[k] = append([]ConfigEntry{}, entries...)
+	}
+
+	for k, entries := range other.entries {
+		key := other.keys[k]
+		merged.keys[k] = key
+
+		if !key.Repeatable {
+			merged.entries[k] = append([]ConfigEntry{}, entries...)
+			continue
+		}
+
+		kept := make([]ConfigEntry, 0, len(merged.entries[k]))
+		for _, existing := range merged.entries[k] {
+			if !overriddenBy(key, existing, entries) {
+				kept = append(kept, existing)
+			}
+		}
+		merged.entries[k] = append(kept, entries...)
+	}
+
+	return merged
+}
+
+func overriddenBy(key ConfigKey, entry ConfigEntry, entries []ConfigEntry) bool {
+	id := entryIdentity(key, entry)
+	if id == "" {
+		return false
+	}
+	for _, e := range entries {
+		if entryIdentity(key, e) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependencies and environment variables are identified by their name
+func entryIdentity(key ConfigKey, entry ConfigEntry) string {
+	switch key.Type {
+	case DependencyValue:
+		return entry.Args[0]
+	case EnvValue:
+		return strings.SplitN(entry.Args[0], "=", 2)[0]
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package languages
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+func writeSidecar(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "kfn-sidecar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar := path.Join(dir, "fn.kfn.yaml")
+	if err := ioutil.WriteFile(sidecar, []byte(content), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return sidecar, func() { os.RemoveAll(dir) }
+}
+
+func TestParseSidecarFileTriggers(t *testing.T) {
+	sidecar, cleanup := writeSidecar(t, "min-scale: 1\ntrigger:\n  - type=dev.knative.example source=/orders\n  - broker=payments type=dev.knative.paid\n")
+	defer cleanup()
+
+	configuration, err := ParseSidecarFile(Javascript, sidecar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []image.Trigger{
+		{Broker: "default", Attributes: map[string]string{"type": "dev.knative.example", "source": "/orders"}},
+		{Broker: "payments", Attributes: map[string]string{"type": "dev.knative.paid"}},
+	}
+	if triggers := configuration.Triggers(); !reflect.DeepEqual(triggers, expected) {
+		t.Errorf("expected the triggers %v, got %v", expected, triggers)
+	}
+}
+
+func TestParseSidecarFileRejectsInvalidTriggers(t *testing.T) {
+	sidecar, cleanup := writeSidecar(t, "trigger:\n  - dev.knative.example\n  - type=a type=b\n")
+	defer cleanup()
+
+	_, err := ParseSidecarFile(Javascript, sidecar)
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected two configuration errors, got %v", err)
+	}
+	for _, e := range errs {
+		if e.Key != TriggerKey {
+			t.Errorf("expected the error of the trigger key, got %v", e)
+		}
+	}
+}
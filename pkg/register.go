@@ -1,12 +1,52 @@
 package pkg
 
 import (
+	"path"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
 	"github.com/slinkydeveloper/kfn/pkg/languages"
+	"github.com/slinkydeveloper/kfn/pkg/languages/golang"
+	"github.com/slinkydeveloper/kfn/pkg/languages/java"
 	"github.com/slinkydeveloper/kfn/pkg/languages/js"
+	"github.com/slinkydeveloper/kfn/pkg/languages/plugin"
+	"github.com/slinkydeveloper/kfn/pkg/languages/python"
 	"github.com/slinkydeveloper/kfn/pkg/languages/rust"
 )
 
 func init() {
-	languages.RegisterLanguageManager(languages.Javascript, js.NewJsLanguageManger())
-	languages.RegisterLanguageManager(languages.Rust, rust.NewRustLanguageManger())
+	languages.SetLanguageLoader(discoverPlugins)
+	registerLanguageManagers(
+		js.NewJsLanguageManger(),
+		rust.NewRustLanguageManger(),
+		python.NewPythonLanguageManager(),
+		golang.NewGoLanguageManager(),
+		js.NewTsLanguageManager(),
+		java.NewJavaLanguageManager(),
+	)
+}
+
+func registerLanguageManagers(managers ...languages.LanguageManager) {
+	for _, m := range managers {
+		if err := languages.RegisterLanguageManager(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// discoverPlugins describes the language plugins, registered only when a language lookup misses the builtin languages,
+// so the commands not resolving a language don't run them. Plugins can't override builtin languages
+func discoverPlugins() []languages.LanguageManager {
+	var managers []languages.LanguageManager
+	for _, executable := range plugin.Discover(path.Join(config.KfnDir, "plugins")) {
+		descriptor, err := plugin.Describe(executable)
+		if err != nil {
+			log.Warnf("Skipping language plugin %s: %v", executable, err)
+			continue
+		}
+
+		log.Debugf("Registering language plugin %s from %s", descriptor.Name, executable)
+		managers = append(managers, plugin.NewPluginLanguageManager(executable, descriptor))
+	}
+	return managers
 }
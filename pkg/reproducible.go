@@ -0,0 +1,56 @@
+package pkg
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+// ReproducibilityCheck compares the images of two reproducible builds of the same function
+type ReproducibilityCheck struct {
+	Platform image.Platform
+	First    image.FunctionImage
+	Second   image.FunctionImage
+}
+
+// Reproducible returns true if the two builds produced the same image. The image ID is the digest of the image
+// configuration, which contains the digests of the layers
+func (c ReproducibilityCheck) Reproducible() bool {
+	return c.First.ID == c.Second.ID
+}
+
+// CheckReproducible builds the function twice in reproducible mode, keeping the images in the local containers storage,
+// and compares the results for every platform. The second build rebuilds the cached stages too
+func CheckReproducible(ctx context.Context, options BuildOptions) ([]ReproducibilityCheck, error) {
+	options.Reproducible = true
+	options.Force = true
+	options.Outputs = []image.Output{{Transport: image.ContainersStorageOutput}}
+
+	log.Info("Running the first build")
+	first, err := Build(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Running the second build")
+	options.noCache = true
+	second, err := Build(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(first.PlatformImages) == 0 {
+		return []ReproducibilityCheck{{First: first.Image, Second: second.Image}}, nil
+	}
+
+	checks := make([]ReproducibilityCheck, len(first.PlatformImages))
+	for i, platformImage := range first.PlatformImages {
+		checks[i] = ReproducibilityCheck{
+			Platform: platformImage.Platform,
+			First:    platformImage.Image,
+			Second:   second.PlatformImages[i].Image,
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/sbom"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+// writeSBOM writes the SBOM of the built image in the target directory and, if requested, attaches it to the pushed image
+func (fn preparedFunction) writeSBOM(ctx context.Context, options BuildOptions, platform image.Platform, functionImage image.FunctionImage) error {
+	format := options.sbomFormat()
+	if format == sbom.None {
+		options.skipStages("SBOM disabled", StageSBOM)
+		return nil
+	}
+
+	return options.runStage(ctx, StageSBOM, func() error {
+		components, err := fn.languageManager.ResolvedDependencies(ctx, fn.configuration, fn.targetDir)
+		if err != nil {
+			return errors.Wrap(err, "cannot read the resolved dependencies")
+		}
+
+		info, err := util.InspectImage(ctx, options.SystemContext, functionImage.FullNameForK8s())
+		if err != nil {
+			return err
+		}
+		if base := info.Labels[image.BaseImageLabel]; base != "" {
+			baseComponent, err := sbom.ImageComponent(base)
+			if err != nil {
+				return errors.Wrap(err, "cannot parse the base image reference")
+			}
+			components = append(components, baseComponent)
+		}
+
+		doc := sbom.Document{
+			Name:        image.FunctionImage{ImageName: functionImage.ImageName}.FullName(),
+			Version:     functionImage.Tag,
+			Created:     time.Now(),
+			ToolVersion: config.Version,
+			Components:  components,
+		}
+		if functionImage.Digest != "" {
+			doc.Version = functionImage.Digest
+		}
+		if sourceDate := options.sourceDate(); sourceDate != nil {
+			doc.Created = *sourceDate
+		}
+
+		data, err := doc.Encode(format)
+		if err != nil {
+			return err
+		}
+
+		fileName := "sbom" + format.Extension()
+		if !platform.IsDefault() {
+			fileName = platform.Tag("sbom") + format.Extension()
+		}
+		if err := ioutil.WriteFile(path.Join(fn.targetDir, fileName), data, 0644); err != nil {
+			return err
+		}
+		log.Infof("SBOM written to %s", path.Join(fn.targetDir, fileName))
+
+		if !options.SBOMAttach {
+			return nil
+		}
+		if functionImage.Digest == "" {
+			log.Warnf("Cannot attach the SBOM to %s, since the image is not pushed to a registry", functionImage.FullName())
+			return nil
+		}
+		attachment, err := functionImage.PushAttachment(ctx, options.SystemContext, "sbom", format.MediaType(), fileName, data)
+		if err != nil {
+			return errors.Wrap(err, "cannot attach the SBOM")
+		}
+		log.Infof("SBOM attached as %s", attachment.FullName())
+		return nil
+	})
+}
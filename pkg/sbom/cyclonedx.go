@@ -0,0 +1,79 @@
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type cdxBom struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cdxComponent struct {
+	Type    string    `json:"type"`
+	Name    string    `json:"name"`
+	Version string    `json:"version,omitempty"`
+	PURL    string    `json:"purl,omitempty"`
+	Hashes  []cdxHash `json:"hashes,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+func encodeCycloneDX(d Document) ([]byte, error) {
+	bom := cdxBom{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+		Metadata: cdxMetadata{
+			Timestamp: d.Created.UTC().Format(time.RFC3339),
+			Tools:     []cdxTool{{Name: "kfn", Version: d.ToolVersion}},
+			Component: cdxComponent{Type: ContainerComponent, Name: d.Name, Version: d.Version},
+		},
+		Components: make([]cdxComponent, 0, len(d.Components)),
+	}
+	for _, c := range d.Components {
+		component := cdxComponent{Type: c.Type, Name: c.Name, Version: c.Version, PURL: c.PURL}
+		for _, h := range c.Hashes {
+			component.Hashes = append(component.Hashes, cdxHash{Alg: h.Algorithm, Content: h.Value})
+		}
+		bom.Components = append(bom.Components, component)
+	}
+
+	content, err := json.Marshal(bom)
+	if err != nil {
+		return nil, err
+	}
+	// The serial number must be an uuid: it's derived from the content instead of being random
+	bom.SerialNumber = "urn:uuid:" + contentUUID(content)
+
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+// contentUUID formats the hash of content as a version 5 like uuid
+func contentUUID(content []byte) string {
+	sum := sha256.Sum256(content)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
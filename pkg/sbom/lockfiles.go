@@ -0,0 +1,156 @@
+package sbom
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// ParseCargoLock reads the crates resolved in a Cargo.lock. The local crates, like the function itself, are skipped
+func ParseCargoLock(file string) ([]Component, error) {
+	tree, err := toml.LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock struct {
+		Package []struct {
+			Name     string `toml:"name"`
+			Version  string `toml:"version"`
+			Source   string `toml:"source"`
+			Checksum string `toml:"checksum"`
+		} `toml:"package"`
+	}
+	if err := tree.Unmarshal(&lock); err != nil {
+		return nil, err
+	}
+
+	var components []Component
+	for _, p := range lock.Package {
+		if p.Source == "" {
+			continue
+		}
+		c := Component{
+			Type:    LibraryComponent,
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    "pkg:cargo/" + p.Name + "@" + p.Version,
+		}
+		if p.Checksum != "" {
+			c.Hashes = []Hash{{Algorithm: "SHA-256", Value: p.Checksum}}
+		}
+		components = append(components, c)
+	}
+	return components, nil
+}
+
+type npmPackage struct {
+	Version      string                `json:"version"`
+	Integrity    string                `json:"integrity"`
+	Link         bool                  `json:"link"`
+	Dependencies map[string]npmPackage `json:"dependencies"`
+}
+
+// ParsePackageLock reads the packages installed by npm from a package-lock.json or npm-shrinkwrap.json,
+// supporting both the nested dependencies of the lockfile version 1 and the packages of the later versions
+func ParsePackageLock(file string) ([]Component, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock struct {
+		Packages     map[string]npmPackage `json:"packages"`
+		Dependencies map[string]npmPackage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var components []Component
+	add := func(name string, p npmPackage) {
+		if p.Link || p.Version == "" || seen[name+"@"+p.Version] {
+			return
+		}
+		seen[name+"@"+p.Version] = true
+		components = append(components, npmComponent(name, p))
+	}
+
+	if len(lock.Packages) != 0 {
+		for key, p := range lock.Packages {
+			// The root project has an empty key, the installed packages are keyed by their path, like node_modules/a/node_modules/b
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 {
+				continue
+			}
+			add(key[i+len("node_modules/"):], p)
+		}
+		return components, nil
+	}
+
+	var walk func(deps map[string]npmPackage)
+	walk = func(deps map[string]npmPackage) {
+		for name, p := range deps {
+			add(name, p)
+			walk(p.Dependencies)
+		}
+	}
+	walk(lock.Dependencies)
+	return components, nil
+}
+
+func npmComponent(name string, p npmPackage) Component {
+	c := Component{
+		Type:    LibraryComponent,
+		Name:    name,
+		Version: p.Version,
+		// The @ of the scoped packages must be encoded, like pkg:npm/%40babel/core@7.6.0
+		PURL: "pkg:npm/" + strings.Replace(name, "@", "%40", 1) + "@" + p.Version,
+	}
+
+	// The integrity is a subresource integrity string, like sha512-<base64 digest>
+	if parts := strings.SplitN(p.Integrity, "-", 2); len(parts) == 2 {
+		if digest, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+			switch parts[0] {
+			case "sha512":
+				c.Hashes = []Hash{{Algorithm: "SHA-512", Value: hex.EncodeToString(digest)}}
+			case "sha1":
+				c.Hashes = []Hash{{Algorithm: "SHA-1", Value: hex.EncodeToString(digest)}}
+			}
+		}
+	}
+	return c
+}
+
+// ParseGoSum reads the modules downloaded to build a go module. The go.mod only entries are skipped,
+// since only the go.mod file of these modules was required to resolve the versions
+func ParseGoSum(file string) ([]Component, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var components []Component
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		components = append(components, Component{
+			Type:    LibraryComponent,
+			Name:    fields[0],
+			Version: fields[1],
+			PURL:    "pkg:golang/" + fields[0] + "@" + fields[1],
+		})
+	}
+	return components, scanner.Err()
+}
@@ -0,0 +1,123 @@
+// Package sbom generates the software bill of materials of the function images,
+// in the CycloneDX and SPDX json formats
+package sbom
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/containers/image/docker/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+type Format string
+
+const (
+	CycloneDX Format = "cyclonedx"
+	SPDX      Format = "spdx"
+	// None disables the SBOM generation
+	None Format = "none"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case CycloneDX, SPDX, None:
+		return Format(value), nil
+	case "":
+		return CycloneDX, nil
+	}
+	return "", fmt.Errorf("unknown SBOM format %s, expected cyclonedx, spdx or none", value)
+}
+
+// Extension of the SBOM files, like sbom.cdx.json
+func (f Format) Extension() string {
+	if f == SPDX {
+		return ".spdx.json"
+	}
+	return ".cdx.json"
+}
+
+// MediaType of the SBOM when attached to the image
+func (f Format) MediaType() string {
+	if f == SPDX {
+		return "application/spdx+json"
+	}
+	return "application/vnd.cyclonedx+json"
+}
+
+const (
+	LibraryComponent   = "library"
+	ContainerComponent = "container"
+)
+
+// Hash of a component, with the algorithm in the CycloneDX notation, like SHA-256
+type Hash struct {
+	Algorithm string
+	Value     string
+}
+
+// Component is a software component bundled in the image
+type Component struct {
+	Type    string
+	Name    string
+	Version string
+	// Package URL, like pkg:npm/express@4.17.1
+	PURL   string
+	Hashes []Hash
+}
+
+// Document describes the image and the components it contains
+type Document struct {
+	// Name and version of the image
+	Name    string
+	Version string
+	Created time.Time
+	// Version of kfn, which generated the document
+	ToolVersion string
+	Components  []Component
+}
+
+// Encode renders the document in the format. The output depends only on the document content,
+// so reproducible builds produce the same SBOM
+func (d Document) Encode(format Format) ([]byte, error) {
+	components := make([]Component, len(d.Components))
+	copy(components, d.Components)
+	sort.SliceStable(components, func(i, j int) bool {
+		if components[i].Name != components[j].Name {
+			return components[i].Name < components[j].Name
+		}
+		return components[i].Version < components[j].Version
+	})
+	d.Components = components
+
+	switch format {
+	case CycloneDX:
+		return encodeCycloneDX(d)
+	case SPDX:
+		return encodeSPDX(d)
+	}
+	return nil, fmt.Errorf("cannot encode the SBOM in format %s", format)
+}
+
+// ImageComponent describes a container image from its reference, like docker.io/library/node:12@sha256:<hex>
+func ImageComponent(ref string) (Component, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return Component{}, err
+	}
+
+	c := Component{Type: ContainerComponent, Name: reference.TrimNamed(named).String()}
+	if tagged, ok := named.(reference.Tagged); ok {
+		c.Version = tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		if c.Version == "" {
+			c.Version = digested.Digest().String()
+		}
+		if digested.Digest().Algorithm() == digest.SHA256 {
+			c.Hashes = []Hash{{Algorithm: "SHA-256", Value: digested.Digest().Hex()}}
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,112 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const noAssertion = "NOASSERTION"
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+func encodeSPDX(d Document) ([]byte, error) {
+	imagePackage := newSPDXPackage("SPDXRef-Image", Component{Name: d.Name, Version: d.Version})
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.2",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        d.Name,
+		CreationInfo: spdxCreationInfo{
+			Created:  d.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: kfn-" + d.ToolVersion},
+		},
+		Packages: []spdxPackage{imagePackage},
+		Relationships: []spdxRelationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: imagePackage.SPDXID},
+		},
+	}
+	for i, c := range d.Components {
+		p := newSPDXPackage(fmt.Sprintf("SPDXRef-Package-%d", i+1), c)
+		doc.Packages = append(doc.Packages, p)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      imagePackage.SPDXID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: p.SPDXID,
+		})
+	}
+
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	// The namespace must be unique for every document: it's derived from the content instead of being random
+	doc.DocumentNamespace = "https://github.com/slinkydeveloper/kfn/spdx/" + d.Name + "-" + contentUUID(content)
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func newSPDXPackage(id string, c Component) spdxPackage {
+	p := spdxPackage{
+		Name:             c.Name,
+		SPDXID:           id,
+		VersionInfo:      c.Version,
+		DownloadLocation: noAssertion,
+		LicenseConcluded: noAssertion,
+		LicenseDeclared:  noAssertion,
+		CopyrightText:    noAssertion,
+	}
+	for _, h := range c.Hashes {
+		// SPDX algorithms are written without the dash, like SHA256
+		p.Checksums = append(p.Checksums, spdxChecksum{Algorithm: strings.Replace(h.Algorithm, "-", "", -1), ChecksumValue: h.Value})
+	}
+	if c.PURL != "" {
+		p.ExternalRefs = []spdxExternalRef{{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: c.PURL}}
+	}
+	return p
+}
@@ -0,0 +1,79 @@
+package signature
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	privateKeyType = "KFN ED25519 PRIVATE KEY"
+	publicKeyType  = "KFN ED25519 PUBLIC KEY"
+)
+
+// GenerateKey writes a new private key to keyFile and its public key to keyFile.pub.
+// It fails if keyFile already exists, to not lose the key signing the existing images
+func GenerateKey(keyFile string) (ed25519.PublicKey, error) {
+	if _, err := os.Stat(keyFile); err == nil {
+		return nil, fmt.Errorf("key %s already exists", keyFile)
+	}
+	if err := os.MkdirAll(path.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: privateKeyType, Bytes: private.Seed()}), 0600); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(keyFile+".pub", pem.EncodeToMemory(&pem.Block{Type: publicKeyType, Bytes: public}), 0644); err != nil {
+		return nil, err
+	}
+	return public, nil
+}
+
+// LoadPrivateKey reads a private key written by GenerateKey
+func LoadPrivateKey(keyFile string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != privateKeyType || len(block.Bytes) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a kfn private key", keyFile)
+	}
+	return ed25519.NewKeyFromSeed(block.Bytes), nil
+}
+
+// LoadPublicKey reads a public key written by GenerateKey. When keyFile is a private key, its public key is returned
+func LoadPublicKey(keyFile string) (ed25519.PublicKey, error) {
+	block, err := readPEM(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case block.Type == publicKeyType && len(block.Bytes) == ed25519.PublicKeySize:
+		return ed25519.PublicKey(block.Bytes), nil
+	case block.Type == privateKeyType && len(block.Bytes) == ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(block.Bytes).Public().(ed25519.PublicKey), nil
+	}
+	return nil, fmt.Errorf("%s is not a kfn key", keyFile)
+}
+
+func readPEM(keyFile string) (*pem.Block, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a pem file", keyFile)
+	}
+	return block, nil
+}
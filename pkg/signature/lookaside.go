@@ -0,0 +1,77 @@
+package signature
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// The signatures are stored locally in a lookaside directory with the layout of the containers signature storage,
+// like <dir>/docker.io/user/function@sha256=<hex>/signature-1
+func signaturesDir(dir string, repository string, manifestDigest string) string {
+	return path.Join(dir, repository+"@"+strings.Replace(manifestDigest, ":", "=", 1))
+}
+
+// Store writes the signature in the lookaside directory, unless it's already there
+func Store(dir string, repository string, manifestDigest string, s Signature) error {
+	data, err := s.Encode()
+	if err != nil {
+		return err
+	}
+
+	imageDir := signaturesDir(dir, repository, manifestDigest)
+	if err := os.MkdirAll(imageDir, 0755); err != nil {
+		return err
+	}
+	for i := 1; ; i++ {
+		file := path.Join(imageDir, fmt.Sprintf("signature-%d", i))
+		existing, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			return ioutil.WriteFile(file, data, 0644)
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(existing, data) {
+			return nil
+		}
+	}
+}
+
+// Load reads the signatures of the image from the lookaside directory
+func Load(dir string, repository string, manifestDigest string) ([]Signature, error) {
+	imageDir := signaturesDir(dir, repository, manifestDigest)
+	var signatures []Signature
+	for i := 1; ; i++ {
+		data, err := ioutil.ReadFile(path.Join(imageDir, fmt.Sprintf("signature-%d", i)))
+		if os.IsNotExist(err) {
+			return signatures, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s, err := Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, s)
+	}
+}
+
+// Encode writes the signature as json
+func (s Signature) Encode() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Decode reads a signature stored as json
+func Decode(data []byte) (Signature, error) {
+	var s Signature
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Signature{}, fmt.Errorf("invalid signature: %v", err)
+	}
+	return s, nil
+}
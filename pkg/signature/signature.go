@@ -0,0 +1,136 @@
+// Package signature signs the digests of the function images with ed25519 keys and verifies them.
+// The signed payload is the simple signing json, the one of the containers policy and of sigstore
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// MediaType of the signatures attached to the images
+const MediaType = "application/vnd.kfn.signature.v1+json"
+
+const payloadType = "atomic container signature"
+
+// Policy decides what happens when an image has no valid signature
+type Policy string
+
+const (
+	// Enforce refuses the images without a valid signature
+	Enforce Policy = "enforce"
+	// Warn logs a warning and accepts the image
+	Warn Policy = "warn"
+	// Disabled skips the verification
+	Disabled Policy = "disabled"
+)
+
+func ParsePolicy(value string) (Policy, error) {
+	switch Policy(value) {
+	case Enforce, Warn, Disabled:
+		return Policy(value), nil
+	case "":
+		return Warn, nil
+	}
+	return "", fmt.Errorf("unknown signature policy %s, expected enforce, warn or disabled", value)
+}
+
+type payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional struct {
+		Creator   string `json:"creator,omitempty"`
+		Timestamp int64  `json:"timestamp,omitempty"`
+	} `json:"optional"`
+}
+
+// Signature of an image digest, stored as json
+type Signature struct {
+	// KeyID is the hash of the public key, to pick the key verifying the signature
+	KeyID     string `json:"keyId"`
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// KeyID returns the hex sha256 of the public key
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sign signs the manifest digest of the image in the repository, like docker.io/user/function
+func Sign(key ed25519.PrivateKey, repository string, manifestDigest string, creator string, timestamp time.Time) (Signature, error) {
+	var p payload
+	p.Critical.Identity.DockerReference = repository
+	p.Critical.Image.DockerManifestDigest = manifestDigest
+	p.Critical.Type = payloadType
+	p.Optional.Creator = creator
+	p.Optional.Timestamp = timestamp.Unix()
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Signature{}, err
+	}
+	return Signature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Payload:   data,
+		Signature: ed25519.Sign(key, data),
+	}, nil
+}
+
+// Verify checks that the signature is made by one of the keys and that it's signing the manifest digest of the repository
+func (s Signature) Verify(keys []ed25519.PublicKey, repository string, manifestDigest string) error {
+	var key ed25519.PublicKey
+	for _, k := range keys {
+		if KeyID(k) == s.KeyID {
+			key = k
+			break
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("signature made by the untrusted key %s", s.KeyID)
+	}
+	if !ed25519.Verify(key, s.Payload, s.Signature) {
+		return fmt.Errorf("invalid signature made by the key %s", s.KeyID)
+	}
+
+	var p payload
+	if err := json.Unmarshal(s.Payload, &p); err != nil {
+		return fmt.Errorf("invalid signature payload: %v", err)
+	}
+	if p.Critical.Type != payloadType {
+		return fmt.Errorf("unknown signature type %s", p.Critical.Type)
+	}
+	if p.Critical.Identity.DockerReference != repository {
+		return fmt.Errorf("signature of %s, expected %s", p.Critical.Identity.DockerReference, repository)
+	}
+	if p.Critical.Image.DockerManifestDigest != manifestDigest {
+		return fmt.Errorf("signature of the digest %s, expected %s", p.Critical.Image.DockerManifestDigest, manifestDigest)
+	}
+	return nil
+}
+
+// VerifyAny checks that at least one of the signatures is valid, returning the error of the last one otherwise
+func VerifyAny(signatures []Signature, keys []ed25519.PublicKey, repository string, manifestDigest string) error {
+	if len(signatures) == 0 {
+		return fmt.Errorf("%s@%s is not signed", repository, manifestDigest)
+	}
+	var err error
+	for _, s := range signatures {
+		if err = s.Verify(keys, repository, manifestDigest); err == nil {
+			return nil
+		}
+	}
+	return err
+}
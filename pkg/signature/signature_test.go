@@ -0,0 +1,164 @@
+package signature
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	testRepository = "docker.io/user/function"
+	testDigest     = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+)
+
+func generateTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return public, private
+}
+
+func sign(t *testing.T, key ed25519.PrivateKey, repository string, manifestDigest string) Signature {
+	s, err := Sign(key, repository, manifestDigest, "kfn", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// signPayload signs an arbitrary payload, to check the payload fields verified after the signature
+func signPayload(t *testing.T, key ed25519.PrivateKey, change func(p *payload)) Signature {
+	var p payload
+	p.Critical.Identity.DockerReference = testRepository
+	p.Critical.Image.DockerManifestDigest = testDigest
+	p.Critical.Type = payloadType
+	change(&p)
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Signature{KeyID: KeyID(key.Public().(ed25519.PublicKey)), Payload: data, Signature: ed25519.Sign(key, data)}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Policy
+		fails    bool
+	}{
+		{value: "enforce", expected: Enforce},
+		{value: "warn", expected: Warn},
+		{value: "disabled", expected: Disabled},
+		{value: "", expected: Warn},
+		{value: "Enforce", fails: true},
+		{value: "strict", fails: true},
+	}
+	for _, test := range tests {
+		policy, err := ParsePolicy(test.value)
+		if test.fails {
+			if err == nil {
+				t.Errorf("expected the policy %q to be refused, got %s", test.value, policy)
+			}
+			continue
+		}
+		if err != nil || policy != test.expected {
+			t.Errorf("expected %s for %q, got %s (%v)", test.expected, test.value, policy, err)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	public, private := generateTestKey(t)
+	otherPublic, otherPrivate := generateTestKey(t)
+
+	tampered := sign(t, private, testRepository, testDigest)
+	tampered.Payload = append([]byte{}, tampered.Payload...)
+	tampered.Payload[len(tampered.Payload)-2] ^= 1
+
+	forgedKeyID := sign(t, otherPrivate, testRepository, testDigest)
+	forgedKeyID.KeyID = KeyID(public)
+
+	tests := []struct {
+		name      string
+		signature Signature
+		keys      []ed25519.PublicKey
+		valid     bool
+	}{
+		{name: "valid", signature: sign(t, private, testRepository, testDigest), keys: []ed25519.PublicKey{public}, valid: true},
+		{name: "valid with many trusted keys", signature: sign(t, private, testRepository, testDigest), keys: []ed25519.PublicKey{otherPublic, public}, valid: true},
+		{name: "untrusted key", signature: sign(t, otherPrivate, testRepository, testDigest), keys: []ed25519.PublicKey{public}},
+		{name: "no trusted keys", signature: sign(t, private, testRepository, testDigest)},
+		{name: "tampered payload", signature: tampered, keys: []ed25519.PublicKey{public}},
+		{name: "key id of another key", signature: forgedKeyID, keys: []ed25519.PublicKey{public}},
+		{name: "other repository", signature: sign(t, private, "docker.io/user/other", testDigest), keys: []ed25519.PublicKey{public}},
+		{name: "other digest", signature: sign(t, private, testRepository, "sha256:fedcba"), keys: []ed25519.PublicKey{public}},
+		{
+			name:      "unknown type",
+			signature: signPayload(t, private, func(p *payload) { p.Critical.Type = "other signature" }),
+			keys:      []ed25519.PublicKey{public},
+		},
+		{
+			name:      "payload not json",
+			signature: Signature{KeyID: KeyID(public), Payload: []byte("digest"), Signature: ed25519.Sign(private, []byte("digest"))},
+			keys:      []ed25519.PublicKey{public},
+		},
+	}
+	for _, test := range tests {
+		err := test.signature.Verify(test.keys, testRepository, testDigest)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected a valid signature, got %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected an invalid signature", test.name)
+		}
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	public, private := generateTestKey(t)
+	_, otherPrivate := generateTestKey(t)
+	valid := sign(t, private, testRepository, testDigest)
+	untrusted := sign(t, otherPrivate, testRepository, testDigest)
+
+	tests := []struct {
+		name       string
+		signatures []Signature
+		valid      bool
+	}{
+		{name: "no signatures"},
+		{name: "only untrusted", signatures: []Signature{untrusted}},
+		{name: "untrusted before valid", signatures: []Signature{untrusted, valid}, valid: true},
+		{name: "valid before untrusted", signatures: []Signature{valid, untrusted}, valid: true},
+	}
+	for _, test := range tests {
+		err := VerifyAny(test.signatures, []ed25519.PublicKey{public}, testRepository, testDigest)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected a valid signature, got %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected no valid signature", test.name)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	public, private := generateTestKey(t)
+	data, err := sign(t, private, testRepository, testDigest).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Verify([]ed25519.PublicKey{public}, testRepository, testDigest); err != nil {
+		t.Errorf("expected the decoded signature to be valid, got %v", err)
+	}
+	if _, err := Decode([]byte("not json")); err == nil {
+		t.Error("expected an invalid signature to be refused")
+	}
+}
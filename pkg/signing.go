@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/containers/image/types"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+	"github.com/slinkydeveloper/kfn/pkg/signature"
+	"golang.org/x/crypto/ed25519"
+)
+
+// VerifyOptions configures the signature verification of the images before deploying them
+type VerifyOptions struct {
+	Policy signature.Policy
+	// Files of the public keys, or of the private keys, trusted to sign the images
+	TrustedKeys   []string
+	SystemContext *types.SystemContext
+}
+
+// signImage signs the digest of the pushed image, storing the signature in the local lookaside directory
+// and attaching it to the image in the registry
+func signImage(ctx context.Context, options BuildOptions, functionImage image.FunctionImage) error {
+	if options.SigningKey == "" {
+		options.skipStages("no signing key", StageSign)
+		return nil
+	}
+
+	return options.runStage(ctx, StageSign, func() error {
+		key, err := signature.LoadPrivateKey(options.SigningKey)
+		if err != nil {
+			return errors.Wrap(err, "cannot load the signing key")
+		}
+
+		repository := imageRepository(functionImage)
+		timestamp := time.Now()
+		if sourceDate := options.sourceDate(); sourceDate != nil {
+			timestamp = *sourceDate
+		}
+		s, err := signature.Sign(key, repository, functionImage.Digest, "kfn "+config.Version, timestamp)
+		if err != nil {
+			return err
+		}
+
+		if err := signature.Store(config.SignaturesDir, repository, functionImage.Digest, s); err != nil {
+			return errors.Wrap(err, "cannot store the signature")
+		}
+		data, err := s.Encode()
+		if err != nil {
+			return err
+		}
+		attachment, err := functionImage.PushAttachment(ctx, options.SystemContext, "sig", signature.MediaType, "signature.json", data)
+		if err != nil {
+			return errors.Wrap(err, "cannot attach the signature")
+		}
+		log.Infof("Image %s@%s signed with the key %s, signature pushed as %s", repository, functionImage.Digest, s.KeyID, attachment.FullName())
+		return nil
+	})
+}
+
+// VerifyImage checks that the image pushed in the registry under the tag of functionImage is signed by one of the trusted keys.
+// The signatures are looked up in the local lookaside directory and then in the registry.
+// With the Enforce policy, unsigned or mismatched images are refused, with the Warn policy a warning is logged.
+// It returns functionImage with the verified digest, which must be deployed instead of the tag, since the tag can be pushed again
+// after the verification. When the image is not verified, the digest of the built image is kept
+func VerifyImage(ctx context.Context, options VerifyOptions, functionImage image.FunctionImage) (image.FunctionImage, error) {
+	if options.Policy == signature.Disabled {
+		return functionImage, nil
+	}
+
+	digest, err := verifyImage(ctx, options, functionImage)
+	if err == nil {
+		log.Infof("Image %s signature verified", functionImage.FullName())
+		functionImage.Digest = digest
+		return functionImage, nil
+	}
+	if options.Policy == signature.Enforce {
+		return image.FunctionImage{}, fmt.Errorf("refusing the image %s: %v", functionImage.FullName(), err)
+	}
+	log.Warnf("Image %s signature not verified: %v", functionImage.FullName(), err)
+	return functionImage, nil
+}
+
+// verifyImage returns the verified digest
+func verifyImage(ctx context.Context, options VerifyOptions, functionImage image.FunctionImage) (string, error) {
+	if len(options.TrustedKeys) == 0 {
+		return "", fmt.Errorf("no trusted keys configured")
+	}
+	keys := make([]ed25519.PublicKey, 0, len(options.TrustedKeys))
+	for _, file := range options.TrustedKeys {
+		key, err := signature.LoadPublicKey(file)
+		if err != nil {
+			return "", err
+		}
+		keys = append(keys, key)
+	}
+
+	// The digest the tag points to now is verified, and then deployed instead of the tag
+	remoteDigest, err := functionImage.RemoteDigest(ctx, options.SystemContext)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot read the image digest")
+	}
+	if functionImage.Digest != "" && functionImage.Digest != remoteDigest {
+		return "", fmt.Errorf("the tag points to %s instead of the built image %s", remoteDigest, functionImage.Digest)
+	}
+	functionImage.Digest = remoteDigest
+
+	// The local and the registry signatures are merged, so a stale or untrusted local signature doesn't hide a valid one.
+	// A source that can't be read is skipped, the verification fails only if no signature is valid
+	repository := imageRepository(functionImage)
+	var signatures []signature.Signature
+	local, localErr := signature.Load(config.SignaturesDir, repository, remoteDigest)
+	if localErr != nil {
+		log.Warnf("Cannot read the local signatures of %s: %v", repository, localErr)
+	}
+	signatures = append(signatures, local...)
+
+	remote, remoteErr := fetchRegistrySignature(ctx, options, functionImage)
+	if remoteErr != nil {
+		log.Warnf("Cannot read the registry signature of %s: %v", repository, remoteErr)
+	}
+	signatures = append(signatures, remote...)
+
+	if len(signatures) == 0 && (localErr != nil || remoteErr != nil) {
+		if localErr != nil {
+			return "", localErr
+		}
+		return "", remoteErr
+	}
+	if err := signature.VerifyAny(signatures, keys, repository, remoteDigest); err != nil {
+		return "", err
+	}
+	return remoteDigest, nil
+}
+
+// fetchRegistrySignature reads the signature attached to the image in the registry, if any
+func fetchRegistrySignature(ctx context.Context, options VerifyOptions, functionImage image.FunctionImage) ([]signature.Signature, error) {
+	data, err := functionImage.FetchAttachment(ctx, options.SystemContext, "sig")
+	if err != nil || data == nil {
+		return nil, err
+	}
+	s, err := signature.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return []signature.Signature{s}, nil
+}
+
+// imageRepository is the identity of the signatures, the image name without the tag
+func imageRepository(functionImage image.FunctionImage) string {
+	return image.FunctionImage{ImageName: functionImage.ImageName}.FullNameForK8s()
+}
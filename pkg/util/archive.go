@@ -0,0 +1,173 @@
+package util
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TarDirectories writes to dest a tar.gz archive of the directories, relative to root. The missing directories are skipped
+func TarDirectories(dest string, root string, dirs ...string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	for _, dir := range dirs {
+		if !DirExist(root, dir) {
+			log.Debugf("Skipping %s, not existing", filepath.Join(root, dir))
+			continue
+		}
+		err := filepath.Walk(filepath.Join(root, dir), func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !info.Mode().IsRegular() {
+				log.Debugf("Skipping %s, not a regular file", p)
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(rel)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			in, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			_, err = io.Copy(tw, in)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// Untar extracts the tar.gz archive src into dest, overwriting the existing files. It returns the extracted files.
+// Only the entries inside the roots directories of dest are accepted, the archive can't replace other files.
+// The files keep only the permission bits of the archive and they are never written through a symlink
+func Untar(src string, dest string, roots ...string) ([]string, error) {
+	var filenames []string
+
+	in, err := os.Open(src)
+	if err != nil {
+		return filenames, err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return filenames, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return filenames, nil
+		}
+		if err != nil {
+			return filenames, err
+		}
+
+		fpath := filepath.Join(dest, header.Name)
+		// Same check of Unzip
+		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return filenames, fmt.Errorf("%s: illegal file path", fpath)
+		}
+		if !insideRoots(dest, fpath, roots) {
+			return filenames, fmt.Errorf("%s: outside of the archive directories %s", header.Name, strings.Join(roots, ", "))
+		}
+		if err := checkNoSymlinks(dest, fpath); err != nil {
+			return filenames, err
+		}
+		mode := os.FileMode(header.Mode) & os.ModePerm
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(fpath, mode|0700); err != nil {
+				return filenames, err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+				return filenames, err
+			}
+			out, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+			if err != nil {
+				return filenames, err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return filenames, err
+			}
+			filenames = append(filenames, fpath)
+		default:
+			log.Debugf("Skipping %s, not a regular file", header.Name)
+		}
+	}
+}
+
+func insideRoots(dest string, fpath string, roots []string) bool {
+	for _, root := range roots {
+		rootPath := filepath.Join(dest, root)
+		if fpath == rootPath || strings.HasPrefix(fpath, rootPath+string(os.PathSeparator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNoSymlinks fails when fpath or one of its existing parents inside dest is a symlink,
+// which would redirect the extracted file outside of dest
+func checkNoSymlinks(dest string, fpath string) error {
+	for p := fpath; p != filepath.Clean(dest); p = filepath.Dir(p) {
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s: refusing to extract through the symlink %s", fpath, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package util
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+type archiveEntry struct {
+	name string
+	mode int64
+}
+
+func writeArchive(t *testing.T, dest string, entries ...archiveEntry) {
+	out, err := os.Create(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		content := []byte("#!/bin/sh\necho pwned\n")
+		header := &tar.Header{Name: e.name, Mode: e.mode, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUntarRejectsEntriesOutsideRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kfn-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dest := path.Join(dir, "kfn")
+	if err := os.Mkdir(dest, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := path.Join(dir, "vendor.tar.gz")
+	writeArchive(t, archive,
+		archiveEntry{name: "vendor/cargo/registry.toml", mode: 0644},
+		archiveEntry{name: "plugins/kfn-lang-foo", mode: 0755},
+	)
+
+	if _, err := Untar(archive, dest, "vendor", "runtime"); err == nil {
+		t.Fatal("expected the plugins entry to be rejected")
+	}
+	if FsExist(dest, "plugins", "kfn-lang-foo") {
+		t.Error("the plugin was extracted")
+	}
+}
+
+func TestUntarStripsSpecialModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kfn-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := path.Join(dir, "vendor.tar.gz")
+	writeArchive(t, archive, archiveEntry{name: "runtime/bin/tool", mode: 06755})
+
+	if _, err := Untar(archive, dir, "vendor", "runtime"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path.Join(dir, "runtime", "bin", "tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&(os.ModeSetuid|os.ModeSetgid) != 0 {
+		t.Errorf("expected no setuid/setgid bits, got %v", info.Mode())
+	}
+}
+
+func TestUntarRefusesSymlinkedParents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kfn-untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dest := path.Join(dir, "kfn")
+	outside := path.Join(dir, "outside")
+	for _, d := range []string{dest, outside} {
+		if err := os.Mkdir(d, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, path.Join(dest, "vendor")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := path.Join(dir, "vendor.tar.gz")
+	writeArchive(t, archive, archiveEntry{name: "vendor/escaped", mode: 0644})
+
+	if _, err := Untar(archive, dest, "vendor", "runtime"); err == nil {
+		t.Fatal("expected the symlinked parent to be refused")
+	}
+	if FsExist(outside, "escaped") {
+		t.Error("the file was written through the symlink")
+	}
+}
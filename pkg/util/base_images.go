@@ -0,0 +1,178 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// baseImagesLockMutex serializes the updates of the lock files by the concurrent builds
+var baseImagesLockMutex sync.Mutex
+
+// BaseImagesLock records the digests the tags of the base images resolved to, keyed by the normalized tagged name
+type BaseImagesLock struct {
+	Images map[string]string `json:"images"`
+}
+
+// BaseImageUpdate is a base image whose tag points to a new digest
+type BaseImageUpdate struct {
+	Name      string
+	OldDigest string
+	NewDigest string
+}
+
+// LoadBaseImagesLock reads the lock file, returning an empty lock if it doesn't exist
+func LoadBaseImagesLock(file string) (BaseImagesLock, error) {
+	lock := BaseImagesLock{Images: map[string]string{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return lock, err
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return lock, err
+	}
+	if lock.Images == nil {
+		lock.Images = map[string]string{}
+	}
+	return lock, nil
+}
+
+func (l BaseImagesLock) Save(file string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// Names returns the locked images, sorted
+func (l BaseImagesLock) Names() []string {
+	names := make([]string, 0, len(l.Images))
+	for name := range l.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pinned returns the image pinned to the recorded digest, false if its tag is not recorded
+func (l BaseImagesLock) Pinned(name string) (string, bool) {
+	taggedName, pinned, err := normalizeTaggedName(name)
+	if err != nil || pinned {
+		return name, pinned
+	}
+	digest, ok := l.Images[taggedName]
+	if !ok {
+		return "", false
+	}
+	return pinnedName(taggedName, digest), true
+}
+
+// normalizeTaggedName returns the name with the registry and the tag, like docker.io/library/node:12.
+// The names already pinned to a digest are not normalized
+func normalizeTaggedName(name string) (string, bool, error) {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return "", false, err
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return named.String(), true, nil
+	}
+	return reference.TagNameOnly(named).String(), false, nil
+}
+
+// PinBaseImage returns the base image pinned to the digest recorded in lockFile, like docker.io/library/node@sha256:<hex>.
+// When the tag is not recorded yet, it's resolved in the registry and recorded, so the following builds use the same digest
+// until the lock is updated. The images already pinned, or that can't be resolved, are returned as they are
+func PinBaseImage(ctx context.Context, systemContext *types.SystemContext, lockFile string, name string) (string, error) {
+	if name == "" {
+		return name, nil
+	}
+	taggedName, pinned, err := normalizeTaggedName(name)
+	if err != nil || pinned {
+		return name, err
+	}
+
+	baseImagesLockMutex.Lock()
+	defer baseImagesLockMutex.Unlock()
+
+	lock, err := LoadBaseImagesLock(lockFile)
+	if err != nil {
+		return "", err
+	}
+	if pinnedImage, ok := lock.Pinned(taggedName); ok {
+		return pinnedImage, nil
+	}
+
+	pinnedImage, err := PinImage(ctx, systemContext, taggedName)
+	if err != nil {
+		log.Warnf("Cannot resolve the digest of the base image %s, using the tag: %v", name, err)
+		return name, nil
+	}
+	lock.Images[taggedName] = pinnedImage[strings.LastIndex(pinnedImage, "@")+1:]
+	log.Infof("Base image %s pinned to %s", taggedName, lock.Images[taggedName])
+	if err := lock.Save(lockFile); err != nil {
+		return "", err
+	}
+	return pinnedImage, nil
+}
+
+// UpdateBaseImages resolves again the tags of the locked images and of the additional ones,
+// recording the new digests in lockFile. It returns the images whose digest changed
+func UpdateBaseImages(ctx context.Context, systemContext *types.SystemContext, lockFile string, additional ...string) ([]BaseImageUpdate, error) {
+	baseImagesLockMutex.Lock()
+	defer baseImagesLockMutex.Unlock()
+
+	lock, err := LoadBaseImagesLock(lockFile)
+	if err != nil {
+		return nil, err
+	}
+	names := lock.Names()
+	for _, name := range additional {
+		taggedName, pinned, err := normalizeTaggedName(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := lock.Images[taggedName]; !pinned && !ok {
+			names = append(names, taggedName)
+		}
+	}
+
+	var updates []BaseImageUpdate
+	for _, name := range names {
+		pinnedImage, err := PinImage(ctx, systemContext, name)
+		if err != nil {
+			return nil, err
+		}
+		digest := pinnedImage[strings.LastIndex(pinnedImage, "@")+1:]
+		if lock.Images[name] != digest {
+			updates = append(updates, BaseImageUpdate{Name: name, OldDigest: lock.Images[name], NewDigest: digest})
+			lock.Images[name] = digest
+		}
+	}
+
+	if len(updates) == 0 {
+		return nil, nil
+	}
+	return updates, lock.Save(lockFile)
+}
+
+// pinnedName replaces the tag of the name with the digest, since the docker transport doesn't support both
+func pinnedName(taggedName string, digest string) string {
+	named, err := reference.ParseNormalizedNamed(taggedName)
+	if err != nil {
+		return taggedName
+	}
+	return reference.TrimNamed(named).String() + "@" + digest
+}
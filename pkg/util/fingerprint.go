@@ -0,0 +1,109 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Fingerprint accumulates the inputs of a build into a sha256 digest.
+// Every input is length prefixed, so different sequences of inputs can't produce the same digest
+type Fingerprint struct {
+	h hash.Hash
+}
+
+func NewFingerprint() *Fingerprint {
+	return &Fingerprint{h: sha256.New()}
+}
+
+func (f *Fingerprint) AddString(values ...string) {
+	for _, v := range values {
+		f.AddBytes([]byte(v))
+	}
+}
+
+func (f *Fingerprint) AddBytes(data []byte) {
+	f.writeLength(int64(len(data)))
+	f.h.Write(data)
+}
+
+func (f *Fingerprint) AddFile(file string) error {
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	f.writeLength(info.Size())
+	_, err = io.Copy(f.h, in)
+	return err
+}
+
+// AddTree adds the relative paths, the permissions and the content of the files in root, skipping the ignored ones
+func (f *Fingerprint) AddTree(root string, ignore IgnoreMatcher) error {
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if ignore.Ignored(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		f.AddString(filepath.ToSlash(rel), info.Mode().String())
+		if info.Mode().IsRegular() {
+			return f.AddFile(p)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			f.AddString(target)
+		}
+		return nil
+	})
+}
+
+// AddResources adds the content of the resources, like the runtime files shipped within kfn
+func (f *Fingerprint) AddResources(loader ResourceLoader, filenames ...string) error {
+	for _, filename := range filenames {
+		data, err := loader.LoadResource(filename)
+		if err != nil {
+			return err
+		}
+		f.AddString(filename)
+		f.AddBytes(data)
+	}
+	return nil
+}
+
+func (f *Fingerprint) Sum() string {
+	return hex.EncodeToString(f.h.Sum(nil))
+}
+
+func (f *Fingerprint) writeLength(length int64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(length))
+	f.h.Write(buf[:])
+}
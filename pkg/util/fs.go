@@ -5,6 +5,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
@@ -229,3 +230,140 @@ func RmR(path ...string) error {
 	}
 	return nil
 }
+
+// CleanDirectory removes the content of the directory, except the entries in keep, creating it if missing.
+// The directories receiving the function sources are cleaned before copying them, so deleted and ignored files don't stay behind
+func CleanDirectory(directory string, keep ...string) error {
+	entries, err := ioutil.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return MkdirpIfNotExists(directory)
+	}
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		kept[k] = true
+	}
+	for _, entry := range entries {
+		if kept[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(path.Join(directory, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyTree copies the content of the source directory into dest, skipping the paths ignored by the matcher
+func CopyTree(source string, dest string, ignore IgnoreMatcher) error {
+	if !DirExist(source) {
+		return fmt.Errorf("Cannot find directory %s", source)
+	}
+
+	log.Debugf("Copying tree %s to %s", source, dest)
+
+	root, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(source, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return MkdirpIfNotExists(dest)
+		}
+
+		if ignore.Ignored(rel, info.IsDir()) {
+			log.Debugf("Ignoring %s", rel)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := path.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode()|0700)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return copySymlink(root, p, target)
+		}
+
+		return copyFile(p, target, info.Mode())
+	})
+}
+
+// copySymlink recreates the symlink p in dest when it points inside the tree root.
+// A link pointing outside the tree is skipped, so it can't leak files of the host into the image
+func copySymlink(root string, p string, dest string) error {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		log.Warnf("Skipping the symlink %s: cannot resolve it: %v", p, err)
+		return nil
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		log.Warnf("Skipping the symlink %s: it points to %s, outside of %s", p, resolved, root)
+		return nil
+	}
+
+	// The link is recreated relative, so it keeps working once the tree is copied elsewhere
+	parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+	if err != nil {
+		return err
+	}
+	link, err := filepath.Rel(parent, resolved)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(link, dest)
+}
+
+func copyFile(source string, dest string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// LinkContent links every top level entry of the source directory into dest, skipping the paths ignored by the matcher
+func LinkContent(source string, dest string, ignore IgnoreMatcher) error {
+	entries, err := ioutil.ReadDir(source)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if ignore.Ignored(e.Name(), e.IsDir()) {
+			continue
+		}
+		if err := Link(path.Join(source, e.Name()), path.Join(dest, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
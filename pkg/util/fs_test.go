@@ -0,0 +1,64 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCopyTreeSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kfn-copy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := path.Join(dir, "function")
+	dest := path.Join(dir, "build")
+	if err := os.MkdirAll(path.Join(source, "lib"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"function/lib/strings.js": "module.exports = {};\n",
+		"secret":                  "private key\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"shared":      "lib",
+		"strings.js":  path.Join(source, "lib", "strings.js"),
+		"id_rsa":      "../secret",
+		"outside_dir": dir,
+		"dangling":    "missing.js",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, path.Join(source, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := CopyTree(source, dest, IgnoreMatcher{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"shared": "lib", "strings.js": "lib/strings.js"} {
+		link, err := os.Readlink(path.Join(dest, name))
+		if err != nil {
+			t.Errorf("expected %s to be copied as a symlink: %v", name, err)
+			continue
+		}
+		if link != expected {
+			t.Errorf("expected %s to point to %s, got %s", name, expected, link)
+		}
+	}
+	if !FileExist(dest, "shared", "strings.js") {
+		t.Error("expected the copied directory link to resolve inside the copy")
+	}
+	for _, name := range []string{"id_rsa", "outside_dir", "dangling"} {
+		if _, err := os.Lstat(path.Join(dest, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be skipped, got %v", name, err)
+		}
+	}
+}
@@ -1,19 +1,14 @@
 package util
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
 )
 
-func DownloadFile(url string, filepath string) error {
-	// Get the data
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
+func DownloadFile(ctx context.Context, url string, filepath string) error {
 	// Create the file
 	out, err := os.Create(filepath)
 	if err != nil {
@@ -21,19 +16,26 @@ func DownloadFile(url string, filepath string) error {
 	}
 	defer out.Close()
 
-	// Write the body to file
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return DownloadAndPipe(ctx, url, out)
 }
 
-func DownloadAndPipe(url string, writer io.Writer) error {
+func DownloadAndPipe(ctx context.Context, url string, writer io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
 	// Get the data
-	resp, err := http.Get(url)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("cannot download %s: %s", url, resp.Status)
+	}
+
 	// Write the body to file
 	_, err = io.Copy(writer, resp.Body)
 	return err
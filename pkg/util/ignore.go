@@ -0,0 +1,115 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KfnIgnoreFile = ".kfnignore"
+)
+
+// Always ignored when copying the function directory
+var defaultIgnorePatterns = []string{".git/", KfnIgnoreFile}
+
+// IgnoreMatcher matches the paths excluded by a .kfnignore file.
+// It supports a subset of the .gitignore syntax:
+// * Blank lines and lines starting with # are skipped
+// * Patterns are shell globs matched against the file name, or against the relative path if they contain a /
+// * A leading / anchors the pattern to the function directory
+// * A trailing / matches only directories
+// * A leading ! negates the pattern. The last matching pattern wins
+type IgnoreMatcher struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	glob     string
+	negate   bool
+	dirOnly  bool
+	withPath bool
+}
+
+// LoadIgnoreFile loads the .kfnignore file in the provided directory, if any
+func LoadIgnoreFile(directory string) (IgnoreMatcher, error) {
+	lines := append([]string{}, defaultIgnorePatterns...)
+
+	ignoreFile := path.Join(directory, KfnIgnoreFile)
+	if FileExist(ignoreFile) {
+		f, err := os.Open(ignoreFile)
+		if err != nil {
+			return IgnoreMatcher{}, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return IgnoreMatcher{}, err
+		}
+	}
+
+	return NewIgnoreMatcher(lines...), nil
+}
+
+func NewIgnoreMatcher(lines ...string) IgnoreMatcher {
+	matcher := IgnoreMatcher{}
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		p := ignorePattern{}
+		if strings.HasPrefix(l, "!") {
+			p.negate = true
+			l = l[1:]
+		}
+		if strings.HasSuffix(l, "/") {
+			p.dirOnly = true
+			l = strings.TrimSuffix(l, "/")
+		}
+		if strings.Contains(l, "/") {
+			p.withPath = true
+			l = strings.TrimPrefix(l, "/")
+		}
+		p.glob = l
+
+		matcher.patterns = append(matcher.patterns, p)
+	}
+	return matcher
+}
+
+// Ignored checks if the path, relative to the function directory, is ignored
+func (m IgnoreMatcher) Ignored(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		var matched bool
+		if p.withPath {
+			matched, _ = path.Match(p.glob, relPath)
+		} else {
+			matched, _ = path.Match(p.glob, path.Base(relPath))
+		}
+
+		if matched {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// With returns a new matcher including the provided patterns, which take precedence over the existing ones
+func (m IgnoreMatcher) With(lines ...string) IgnoreMatcher {
+	other := NewIgnoreMatcher(lines...)
+	return IgnoreMatcher{patterns: append(append([]ignorePattern{}, m.patterns...), other.patterns...)}
+}
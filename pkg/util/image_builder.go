@@ -5,26 +5,35 @@ import (
 	"fmt"
 	"github.com/containers/buildah"
 	"github.com/containers/buildah/pkg/unshare"
+	butil "github.com/containers/buildah/util"
+	is "github.com/containers/image/storage"
+	"github.com/containers/image/transports/alltransports"
 	"github.com/containers/image/types"
 	"github.com/containers/storage"
 	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	log "github.com/sirupsen/logrus"
 	"github.com/slinkydeveloper/kfn/pkg/config"
 	"github.com/slinkydeveloper/kfn/pkg/image"
+	"io/ioutil"
+	"os"
+	"os/exec"
 	"strings"
+	"time"
 )
 
-var digester = digest.Canonical.Digester()
-
-func InitializeBuilder(ctx context.Context, systemContext *types.SystemContext, fromImage string) (*buildah.Builder, error) {
+// The local containers storage, where the images are built before pushing them
+func getStore() (storage.Store, error) {
 	buildStoreOptions, err := storage.DefaultStoreOptions(unshare.IsRootless(), unshare.GetRootlessUID())
-
 	if err != nil {
 		return nil, err
 	}
 
-	buildStore, err := storage.GetStore(buildStoreOptions)
+	return storage.GetStore(buildStoreOptions)
+}
 
+func InitializeBuilder(ctx context.Context, systemContext *types.SystemContext, fromImage string) (*buildah.Builder, error) {
+	buildStore, err := getStore()
 	if err != nil {
 		return nil, err
 	}
@@ -48,10 +57,11 @@ type BuildAdd struct {
 	To   string
 }
 
-func Add(builder *buildah.Builder, adds ...BuildAdd) error {
+// Add copies the files into the builder container. When chown is not empty, it's the owner of the copied files, like 0:0
+func Add(builder *buildah.Builder, chown string, adds ...BuildAdd) error {
 	for _, add := range adds {
 		log.Infof("Copying into container image %s to %s", add.From, add.To)
-		err := builder.Add(add.To, false, buildah.AddAndCopyOptions{Hasher: digester.Hash()}, add.From)
+		err := builder.Add(add.To, false, buildah.AddAndCopyOptions{Chown: chown}, add.From)
 		if err != nil {
 			return fmt.Errorf("error while adding: %v", err)
 		}
@@ -62,9 +72,14 @@ func Add(builder *buildah.Builder, adds ...BuildAdd) error {
 type BuildCommand struct {
 	Command string
 	Wd      string
+	// Additional environment variables in the form NAME=VALUE
+	Env []string
+	// Host directories mounted while running the command, like the caches of the package managers
+	Mounts []BuildMount
 }
 
-func RunCommands(builder *buildah.Builder, commands ...BuildCommand) error {
+// RunCommands runs the commands in the builder container. When ctx is done, the running command is killed
+func RunCommands(ctx context.Context, builder *buildah.Builder, commands ...BuildCommand) error {
 	logger := config.GetLoggerWriter()
 	runOptions := buildah.RunOptions{
 		Stdout:    logger,
@@ -72,6 +87,10 @@ func RunCommands(builder *buildah.Builder, commands ...BuildCommand) error {
 		Isolation: config.BuildahIsolation,
 	}
 	for _, cmd := range commands {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		log.Infof("Running command %s in directory %s", cmd.Command, cmd.Wd)
 
 		command := strings.Split(cmd.Command, " ")
@@ -79,30 +98,260 @@ func RunCommands(builder *buildah.Builder, commands ...BuildCommand) error {
 		if cmd.Wd != "" {
 			runOptions.WorkingDir = cmd.Wd
 		}
+		runOptions.Env = cmd.Env
+		runOptions.Mounts = bindMounts(cmd.Mounts)
+
+		if err := runCancellable(ctx, builder, command, runOptions); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("error while runnning command: %v", err)
+		}
+	}
+	return nil
+}
+
+type BuildMount struct {
+	Source      string
+	Destination string
+}
+
+// RunInContainer runs the commands inside a throwaway container created from fromImage,
+// with the provided host directories bind mounted. The container is removed when finished
+func RunInContainer(ctx context.Context, systemContext *types.SystemContext, fromImage string, mounts []BuildMount, commands ...BuildCommand) error {
+	builder, err := InitializeBuilder(ctx, systemContext, fromImage)
+	if err != nil {
+		return err
+	}
+	defer DeleteBuilder(builder)
+
+	logger := config.GetLoggerWriter()
+	runOptions := buildah.RunOptions{
+		Stdout:    logger,
+		Stderr:    logger,
+		Isolation: config.BuildahIsolation,
+	}
+	runOptions.Mounts = bindMounts(mounts)
+
+	for _, cmd := range commands {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		log.Infof("Running command %s in directory %s of %s", cmd.Command, cmd.Wd, fromImage)
+
+		if cmd.Wd != "" {
+			runOptions.WorkingDir = cmd.Wd
+		}
+		runOptions.Env = cmd.Env
 
-		if err := builder.Run(command, runOptions); err != nil {
+		if err := runCancellable(ctx, builder, strings.Split(cmd.Command, " "), runOptions); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			return fmt.Errorf("error while runnning command: %v", err)
 		}
 	}
 	return nil
 }
 
-func CommitImage(builder *buildah.Builder, ctx *types.SystemContext, imageName string, imageTag string) (image.FunctionImage, error) {
+// runCancellable runs the command in the builder container. Buildah doesn't accept a context, so the OCI runtime
+// keeps the state of the container in a directory owned by this run and, when ctx is done, the container is killed through it.
+// The chroot isolation doesn't use the OCI runtime, so the command can't be interrupted and kfn waits for it
+func runCancellable(ctx context.Context, builder *buildah.Builder, command []string, options buildah.RunOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if options.Isolation == buildah.IsolationChroot {
+		return builder.Run(command, options)
+	}
+
+	stateDir, err := ioutil.TempDir("", "kfn-runtime")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stateDir)
+
+	runtime := options.Runtime
+	if runtime == "" {
+		runtime = butil.Runtime()
+	}
+	options.Args = append([]string{"--root", stateDir}, options.Args...)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+		log.Debugf("Killing %s: %v", strings.Join(command, " "), ctx.Err())
+		// The container could still be starting, so it's killed until the run returns
+		for {
+			killContainers(runtime, options.Args)
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+
+	return builder.Run(command, options)
+}
+
+// killContainers kills all the containers of the OCI runtime state directory in runtimeArgs
+func killContainers(runtime string, runtimeArgs []string) {
+	runtimeCommand := func(args ...string) *exec.Cmd {
+		return exec.Command(runtime, append(append([]string{}, runtimeArgs...), args...)...)
+	}
+	out, err := runtimeCommand("list", "-q").Output()
+	if err != nil {
+		log.Debugf("Cannot list the builder containers: %v", err)
+		return
+	}
+	for _, id := range strings.Fields(string(out)) {
+		if err := runtimeCommand("kill", id, "KILL").Run(); err != nil {
+			log.Debugf("Cannot kill the builder container %s: %v", id, err)
+		}
+	}
+}
+
+func bindMounts(mounts []BuildMount) []specs.Mount {
+	var specMounts []specs.Mount
+	for _, m := range mounts {
+		log.Debugf("Mounting %s to %s", m.Source, m.Destination)
+		specMounts = append(specMounts, specs.Mount{
+			Source:      m.Source,
+			Destination: m.Destination,
+			Type:        "bind",
+			Options:     []string{"rbind", "rw"},
+		})
+	}
+	return specMounts
+}
+
+// DeleteBuilder removes the working container of the builder. Every builder must be deleted when the build
+// finishes, even if it failed or it was cancelled
+func DeleteBuilder(builder *buildah.Builder) {
+	if err := builder.Delete(); err != nil {
+		log.Warnf("Cannot remove builder container %s: %v", builder.Container, err)
+	}
+}
+
+// CommitImage commits the image in the local containers storage, the image is pushed later with PushImage.
+// When created is not nil, it replaces the current time as creation time of the image
+func CommitImage(ctx context.Context, builder *buildah.Builder, systemContext *types.SystemContext, imageName string, imageTag string, created *time.Time) (image.FunctionImage, error) {
 	img := image.FunctionImage{
 		ImageName: imageName,
 		Tag:       imageTag,
 	}
 
-	imageRef, err := img.ParseSpecDest()
-
+	var err error
+	img.ID, err = commitToStore(ctx, builder, systemContext, img.FullNameForK8s(), created)
 	if err != nil {
 		return image.FunctionImage{}, err
 	}
 
-	_, _, _, err = builder.Commit(context.TODO(), imageRef, buildah.CommitOptions{
+	return img, nil
+}
+
+// commitToStore commits the builder in the local containers storage with the provided name, returning the image id
+func commitToStore(ctx context.Context, builder *buildah.Builder, systemContext *types.SystemContext, name string, created *time.Time) (string, error) {
+	store, err := getStore()
+	if err != nil {
+		return "", err
+	}
+
+	imageRef, err := is.Transport.ParseStoreReference(store, name)
+	if err != nil {
+		return "", err
+	}
+
+	id, _, _, err := builder.Commit(ctx, imageRef, buildah.CommitOptions{
 		PreferredManifestType: buildah.Dockerv2ImageManifest,
-		SystemContext:         ctx,
+		SystemContext:         systemContext,
+		HistoryTimestamp:      created,
+	})
+	return id, err
+}
+
+// PushImage pushes the image committed in the local storage to the registry, returning it with the pushed digest
+func PushImage(ctx context.Context, systemContext *types.SystemContext, img image.FunctionImage) (image.FunctionImage, error) {
+	dest, err := img.ParseSpecDest()
+	if err != nil {
+		return image.FunctionImage{}, err
+	}
+
+	manifestDigest, err := copyFromStore(ctx, systemContext, img, dest)
+	if err != nil {
+		return image.FunctionImage{}, fmt.Errorf("error while pushing %s: %v", img.FullName(), err)
+	}
+
+	img.Digest = manifestDigest.String()
+	return img, nil
+}
+
+// ExportImage writes the image committed in the local storage to an archive or layout output
+func ExportImage(ctx context.Context, systemContext *types.SystemContext, img image.FunctionImage, output image.Output) error {
+	dest, err := output.Reference(img)
+	if err != nil {
+		return err
+	}
+
+	if _, err := copyFromStore(ctx, systemContext, img, dest); err != nil {
+		return fmt.Errorf("error while exporting %s to %s: %v", img.FullName(), output, err)
+	}
+	return nil
+}
+
+func copyFromStore(ctx context.Context, systemContext *types.SystemContext, img image.FunctionImage, dest types.ImageReference) (digest.Digest, error) {
+	store, err := getStore()
+	if err != nil {
+		return "", err
+	}
+
+	_, manifestDigest, err := buildah.Push(ctx, img.ID, dest, buildah.PushOptions{
+		Store:         store,
+		SystemContext: systemContext,
+		ReportWriter:  config.GetLoggerWriter(),
 	})
+	return manifestDigest, err
+}
+
+// InspectImage reads the configuration of the image from the local containers storage or, when it isn't there, from the registry.
+// References with a transport, like oci-archive:/tmp/function.tar, are read from that transport
+func InspectImage(ctx context.Context, systemContext *types.SystemContext, name string) (*types.ImageInspectInfo, error) {
+	if ref, err := alltransports.ParseImageName(name); err == nil {
+		return inspectReference(ctx, systemContext, ref)
+	}
+
+	store, err := getStore()
+	if err != nil {
+		return nil, err
+	}
+	if ref, err := is.Transport.ParseStoreReference(store, name); err == nil {
+		info, err := inspectReference(ctx, systemContext, ref)
+		if err == nil {
+			return info, nil
+		}
+		log.Debugf("Image %s not found in the local storage, looking in the registry: %v", name, err)
+	}
+
+	ref, err := alltransports.ParseImageName("docker://" + name)
+	if err != nil {
+		return nil, err
+	}
+	return inspectReference(ctx, systemContext, ref)
+}
+
+func inspectReference(ctx context.Context, systemContext *types.SystemContext, ref types.ImageReference) (*types.ImageInspectInfo, error) {
+	img, err := ref.NewImage(ctx, systemContext)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
 
-	return img, err
+	return img.Inspect(ctx)
 }
@@ -0,0 +1,467 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containers/buildah"
+	"github.com/containers/image/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+const (
+	Containerfile = "Containerfile"
+	// Repository of the cached base stages in the local containers storage, tagged with their fingerprint
+	cachedStageRepository = "localhost/kfn-cache"
+)
+
+type BuildEnv struct {
+	Name  string
+	Value string
+}
+
+// ImageRecipe describes how to build the function image, so the same steps can be executed by buildah
+// or rendered to a Containerfile
+type ImageRecipe struct {
+	// Base image, empty for scratch
+	BaseImage string
+	Port      string
+	Add       []BuildAdd
+	Run       []BuildCommand
+	Env       []BuildEnv
+	User      string
+	WorkDir   string
+	Labels    map[string]string
+	// Entrypoint and Cmd are not changed when nil. An empty slice resets the one of the base image
+	Entrypoint []string
+	Cmd        []string
+	// CopyOut lists the files copied from the built image to the host, like the lockfiles generated by the Run commands.
+	// From is the path in the image. Missing files are skipped
+	CopyOut []BuildAdd
+	// BaseStage, when not nil, replaces BaseImage. It's built first and cached in the local containers storage,
+	// keyed by the hash of its base image, files and steps, so the following builds reuse it while its inputs don't change.
+	// It's meant for expensive steps that change rarely, like installing the dependencies
+	BaseStage *ImageRecipe
+}
+
+// RecipeBuildOptions configures how BuildRecipe builds the image
+type RecipeBuildOptions struct {
+	// The base images are pulled for the platform, choosing the entry of the manifest lists matching the os, the architecture and the variant
+	Platform image.Platform
+	// SourceDate, when not nil, makes the build reproducible: the base images are pinned by digest, the added files
+	// are owned by root and the timestamps of the changed files and of the image are set to SourceDate
+	SourceDate *time.Time
+	// NoCache rebuilds the base stage even if it's cached
+	NoCache bool
+}
+
+// BuildRecipe builds the recipe and commits the image in the local containers storage
+func BuildRecipe(ctx context.Context, systemContext *types.SystemContext, recipe ImageRecipe, options RecipeBuildOptions, imageName string, imageTag string) (image.FunctionImage, error) {
+	builder, err := initializeRecipeBuilder(ctx, systemContext, recipe, options)
+	if err != nil {
+		return image.FunctionImage{}, err
+	}
+	defer DeleteBuilder(builder)
+
+	if err := applyRecipe(ctx, builder, recipe, options); err != nil {
+		return image.FunctionImage{}, err
+	}
+
+	if err := copyOut(builder, recipe.CopyOut); err != nil {
+		return image.FunctionImage{}, err
+	}
+
+	return CommitImage(ctx, builder, systemContext, imageName, imageTag, options.SourceDate)
+}
+
+func initializeRecipeBuilder(ctx context.Context, systemContext *types.SystemContext, recipe ImageRecipe, options RecipeBuildOptions) (*buildah.Builder, error) {
+	fromImage := recipe.BaseImage
+	if recipe.BaseStage != nil {
+		var err error
+		fromImage, err = buildCachedStage(ctx, systemContext, *recipe.BaseStage, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	platform := options.Platform
+	var platformContext types.SystemContext
+	if systemContext != nil {
+		platformContext = *systemContext
+	}
+	if !platform.IsDefault() {
+		platformContext.OSChoice = platform.OS
+		platformContext.ArchitectureChoice = platform.Architecture
+	}
+
+	// containers/image chooses only the OS and the architecture from the manifest lists, the variant is resolved by kfn
+	if platform.Variant != "" && recipe.BaseStage == nil {
+		var err error
+		fromImage, err = ResolvePlatformImage(ctx, &platformContext, fromImage, platform)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.SourceDate != nil && recipe.BaseStage == nil {
+		var err error
+		fromImage, err = PinImage(ctx, &platformContext, fromImage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	builder, err := InitializeBuilder(ctx, &platformContext, fromImage)
+	if err != nil {
+		return nil, err
+	}
+	if !platform.IsDefault() {
+		// Images from scratch don't inherit the platform from the base image
+		builder.SetOS(platform.OS)
+		builder.SetArchitecture(platform.Architecture)
+	}
+	if options.SourceDate != nil {
+		// Otherwise buildah generates a random hostname, stored in the image configuration
+		builder.SetHostname("")
+	}
+	// Images built on a base stage inherit the label from the stage
+	if recipe.BaseStage == nil && builder.FromImage != "" {
+		builder.SetLabel(image.BaseImageLabel, baseImageReference(builder))
+	}
+	return builder, nil
+}
+
+// baseImageReference returns the name of the base image together with its digest
+func baseImageReference(builder *buildah.Builder) string {
+	if builder.FromImageDigest == "" || strings.Contains(builder.FromImage, "@") {
+		return builder.FromImage
+	}
+	return builder.FromImage + "@" + builder.FromImageDigest
+}
+
+// copyOut copies the files from the builder container to the host
+func copyOut(builder *buildah.Builder, files []BuildAdd) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	mountPoint, err := builder.Mount(builder.MountLabel)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := builder.Unmount(); err != nil {
+			log.Warnf("Cannot unmount builder container %s: %v", builder.Container, err)
+		}
+	}()
+
+	for _, f := range files {
+		source := filepath.Join(mountPoint, f.From)
+		// Only regular files are copied, a symlink could point outside the container
+		info, err := os.Lstat(source)
+		if err != nil || !info.Mode().IsRegular() {
+			log.Debugf("Skipping the copy of %s from the image: not a regular file", f.From)
+			continue
+		}
+
+		log.Infof("Copying from container image %s to %s", f.From, f.To)
+		if err := MkdirpIfNotExists(filepath.Dir(f.To)); err != nil {
+			return err
+		}
+		if err := copyFile(source, f.To, info.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildCachedStage returns the name of the stage image in the local storage, building it if missing
+func buildCachedStage(ctx context.Context, systemContext *types.SystemContext, stage ImageRecipe, options RecipeBuildOptions) (string, error) {
+	key, err := stage.fingerprint(options.Platform)
+	if err != nil {
+		return "", err
+	}
+	if options.SourceDate != nil {
+		key = reproducibleStageKey(key, *options.SourceDate)
+	}
+	stageImage := cachedStageRepository + ":" + key
+
+	store, err := getStore()
+	if err != nil {
+		return "", err
+	}
+	if _, err := store.Image(stageImage); err == nil && !options.NoCache {
+		log.Infof("Reusing cached stage %s", stageImage)
+		return stageImage, nil
+	}
+
+	log.Infof("Building cached stage %s", stageImage)
+	builder, err := initializeRecipeBuilder(ctx, systemContext, stage, options)
+	if err != nil {
+		return "", err
+	}
+	defer DeleteBuilder(builder)
+
+	if err := applyRecipe(ctx, builder, stage, options); err != nil {
+		return "", err
+	}
+
+	if _, err := commitToStore(ctx, builder, systemContext, stageImage, options.SourceDate); err != nil {
+		return "", err
+	}
+	return stageImage, nil
+}
+
+// Stages of reproducible builds are cached separately, since their layers are normalized
+func reproducibleStageKey(key string, sourceDate time.Time) string {
+	fingerprint := NewFingerprint()
+	fingerprint.AddString(key, "reproducible", sourceDate.UTC().Format(time.RFC3339))
+	return fingerprint.Sum()
+}
+
+// fingerprint hashes everything that affects the image built by the recipe for the platform
+func (r ImageRecipe) fingerprint(platform image.Platform) (string, error) {
+	fingerprint := NewFingerprint()
+	fingerprint.AddString(platform.String())
+	if r.BaseStage != nil {
+		baseKey, err := r.BaseStage.fingerprint(platform)
+		if err != nil {
+			return "", err
+		}
+		fingerprint.AddString("stage", baseKey)
+	} else {
+		fingerprint.AddString("image", r.BaseImage)
+	}
+
+	fingerprint.AddString(r.Port, r.User, r.WorkDir)
+	for _, add := range r.Add {
+		fingerprint.AddString(add.To)
+		info, err := os.Stat(add.From)
+		if err != nil {
+			return "", err
+		}
+		if info.IsDir() {
+			err = fingerprint.AddTree(add.From, IgnoreMatcher{})
+		} else {
+			err = fingerprint.AddFile(add.From)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	for _, cmd := range r.Run {
+		fingerprint.AddString(cmd.Command, cmd.Wd, strings.Join(cmd.Env, "\n"))
+	}
+	for _, env := range r.Env {
+		fingerprint.AddString(env.Name, env.Value)
+	}
+	for _, key := range sortedKeys(r.Labels) {
+		fingerprint.AddString(key, r.Labels[key])
+	}
+	fingerprint.AddString(strings.Join(r.Entrypoint, " "), strings.Join(r.Cmd, " "))
+
+	return fingerprint.Sum(), nil
+}
+
+func applyRecipe(ctx context.Context, builder *buildah.Builder, recipe ImageRecipe, options RecipeBuildOptions) error {
+	if recipe.Port != "" {
+		builder.SetPort(recipe.Port)
+	}
+
+	owner := ""
+	if options.SourceDate != nil {
+		owner = reproducibleOwner
+	}
+	if err := Add(builder, owner, recipe.Add...); err != nil {
+		return err
+	}
+	// The added files are normalized before running the commands, since they can embed the timestamps of the sources
+	if options.SourceDate != nil && len(recipe.Add) != 0 {
+		if err := normalizeChanges(builder, *options.SourceDate); err != nil {
+			return err
+		}
+	}
+
+	if err := RunCommands(ctx, builder, recipe.Run...); err != nil {
+		return err
+	}
+	if options.SourceDate != nil {
+		if err := normalizeChanges(builder, *options.SourceDate); err != nil {
+			return err
+		}
+	}
+
+	for _, env := range recipe.Env {
+		builder.SetEnv(env.Name, env.Value)
+	}
+	if recipe.User != "" {
+		builder.SetUser(recipe.User)
+	}
+	if recipe.WorkDir != "" {
+		builder.SetWorkDir(recipe.WorkDir)
+	}
+	for _, key := range sortedKeys(recipe.Labels) {
+		builder.SetLabel(key, recipe.Labels[key])
+	}
+	if recipe.Entrypoint != nil {
+		builder.SetEntrypoint(recipe.Entrypoint)
+	}
+	if recipe.Cmd != nil {
+		builder.SetCmd(recipe.Cmd)
+	}
+	return nil
+}
+
+// WriteBuildContext writes to destination a Containerfile equivalent to the recipe built for the platform, together with the added files.
+// Files inside the target directory keep their relative path, the other ones are copied in the files directory
+func WriteBuildContext(recipe ImageRecipe, platform image.Platform, targetDirectory string, destination string) error {
+	if err := MkdirpIfNotExists(destination); err != nil {
+		return err
+	}
+
+	var containerfile bytes.Buffer
+	if err := writeContainerfileSteps(&containerfile, recipe, platform, targetDirectory, destination); err != nil {
+		return err
+	}
+
+	return WriteFiles(destination, WriteDest{Filename: Containerfile, Data: containerfile.Bytes()})
+}
+
+// The steps of the base stage are written first, the layer cache of the builder plays the role of the cached stage
+func writeContainerfileSteps(containerfile *bytes.Buffer, recipe ImageRecipe, platform image.Platform, targetDirectory string, destination string) error {
+	if recipe.BaseStage != nil {
+		if err := writeContainerfileSteps(containerfile, *recipe.BaseStage, platform, targetDirectory, destination); err != nil {
+			return err
+		}
+	} else {
+		writeFrom(containerfile, recipe.BaseImage, platform)
+	}
+
+	if recipe.Port != "" {
+		fmt.Fprintf(containerfile, "EXPOSE %s\n", recipe.Port)
+	}
+
+	for _, add := range recipe.Add {
+		contextPath, err := copyToBuildContext(add.From, targetDirectory, destination)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(containerfile, "COPY %s\n", jsonArray([]string{contextPath, add.To}))
+	}
+
+	for _, cmd := range recipe.Run {
+		// The mounted directories are host specific, so they're left to the user
+		for _, m := range cmd.Mounts {
+			fmt.Fprintf(containerfile, "# Mount %s at %s to run the next command\n", m.Source, m.Destination)
+		}
+		// RUN doesn't have working directory and environment options, while WORKDIR and ENV would change the ones of the image
+		if cmd.Wd != "" || len(cmd.Env) != 0 {
+			command := cmd.Command
+			if len(cmd.Env) != 0 {
+				env := make([]string, 0, len(cmd.Env))
+				for _, e := range cmd.Env {
+					env = append(env, shellQuote(e))
+				}
+				command = "env " + strings.Join(env, " ") + " " + command
+			}
+			if cmd.Wd != "" {
+				command = "cd " + shellQuote(cmd.Wd) + " && " + command
+			}
+			fmt.Fprintf(containerfile, "RUN %s\n", command)
+		} else {
+			fmt.Fprintf(containerfile, "RUN %s\n", jsonArray(strings.Split(cmd.Command, " ")))
+		}
+	}
+
+	for _, env := range recipe.Env {
+		fmt.Fprintf(containerfile, "ENV %s=%s\n", env.Name, jsonString(env.Value))
+	}
+	if recipe.User != "" {
+		fmt.Fprintf(containerfile, "USER %s\n", recipe.User)
+	}
+	if recipe.WorkDir != "" {
+		fmt.Fprintf(containerfile, "WORKDIR %s\n", recipe.WorkDir)
+	}
+	for _, key := range sortedKeys(recipe.Labels) {
+		fmt.Fprintf(containerfile, "LABEL %s=%s\n", key, jsonString(recipe.Labels[key]))
+	}
+	// ENTRYPOINT resets CMD, so it must come first
+	if recipe.Entrypoint != nil {
+		fmt.Fprintf(containerfile, "ENTRYPOINT %s\n", jsonArray(recipe.Entrypoint))
+	}
+	if recipe.Cmd != nil {
+		fmt.Fprintf(containerfile, "CMD %s\n", jsonArray(recipe.Cmd))
+	}
+	return nil
+}
+
+// The platform is declared when it's not the default one, otherwise the builder would pull the base image of its own platform
+func writeFrom(containerfile *bytes.Buffer, baseImage string, platform image.Platform) {
+	from := baseImage
+	if from == "" {
+		from = "scratch"
+	}
+	if !platform.IsDefault() {
+		fmt.Fprintf(containerfile, "FROM --platform=%s %s\n", platform, from)
+		return
+	}
+	fmt.Fprintf(containerfile, "FROM %s\n", from)
+}
+
+func copyToBuildContext(source string, targetDirectory string, destination string) (string, error) {
+	contextPath := path.Join("files", filepath.Base(source))
+	if rel, err := filepath.Rel(targetDirectory, source); err == nil && !strings.HasPrefix(rel, "..") {
+		contextPath = filepath.ToSlash(rel)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+
+	dest := path.Join(destination, contextPath)
+	if info.IsDir() {
+		return contextPath, CopyTree(source, dest, IgnoreMatcher{})
+	}
+
+	if err := MkdirpIfNotExists(path.Dir(dest)); err != nil {
+		return "", err
+	}
+	return contextPath, copyFile(source, dest, info.Mode())
+}
+
+func jsonArray(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// shellQuote quotes the value for sh, so spaces and special characters are kept as they are
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func jsonString(value string) string {
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+func TestWriteBuildContext(t *testing.T) {
+	destination, err := ioutil.TempDir("", "kfn-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(destination)
+
+	recipe := ImageRecipe{
+		BaseImage: "docker.io/library/node:12",
+		Run: []BuildCommand{{
+			Command: "npm install",
+			Wd:      "/home/node/usr",
+			Env:     []string{"GREETING=Hello world", "QUOTE=it's"},
+		}},
+	}
+	platform := image.Platform{OS: "linux", Architecture: "arm64"}
+	if err := WriteBuildContext(recipe, platform, destination, destination); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(destination, Containerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"FROM --platform=linux/arm64 docker.io/library/node:12\n",
+		`RUN cd '/home/node/usr' && env 'GREETING=Hello world' 'QUOTE=it'\''s' npm install` + "\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(content), e) {
+			t.Errorf("expected the Containerfile to contain %q, got:\n%s", e, content)
+		}
+	}
+}
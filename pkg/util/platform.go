@@ -0,0 +1,91 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+// Common fields of the docker manifest lists and of the OCI image indexes
+type platformManifestList struct {
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// ResolvePlatformImage returns the image name pinned to the manifest of the platform, looked up in the manifest list of the image.
+// Images from scratch are returned as they are, while images without a manifest list can't be resolved for a variant
+func ResolvePlatformImage(ctx context.Context, systemContext *types.SystemContext, name string, platform image.Platform) (string, error) {
+	if name == "" {
+		return name, nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return "", err
+	}
+	if _, digested := named.(reference.Digested); !digested {
+		named = reference.TagNameOnly(named)
+	}
+	ref, err := alltransports.ParseImageName("docker://" + named.String())
+	if err != nil {
+		return "", err
+	}
+
+	src, err := ref.NewImageSource(ctx, systemContext)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve the image %s for %s: %v", name, platform, err)
+	}
+	defer src.Close()
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve the image %s for %s: %v", name, platform, err)
+	}
+	platformDigest, err := findPlatformManifest(rawManifest, mimeType, platform)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve the image %s for %s: %v", name, platform, err)
+	}
+
+	pinned, err := reference.WithDigest(reference.TrimNamed(named), platformDigest)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Resolved image %s for %s to %s", name, platform, pinned)
+	return pinned.String(), nil
+}
+
+// findPlatformManifest returns the digest of the manifest list entry matching os, architecture and variant of the platform
+func findPlatformManifest(rawManifest []byte, mimeType string, platform image.Platform) (digest.Digest, error) {
+	if mimeType == "" {
+		mimeType = manifest.GuessMIMEType(rawManifest)
+	}
+	if mimeType != manifest.DockerV2ListMediaType && mimeType != imgspecv1.MediaTypeImageIndex {
+		return "", fmt.Errorf("the image is not a manifest list, so its variant can't be chosen")
+	}
+
+	var list platformManifestList
+	if err := json.Unmarshal(rawManifest, &list); err != nil {
+		return "", err
+	}
+	for _, m := range list.Manifests {
+		if m.Platform.OS == platform.OSOrDefault() && m.Platform.Architecture == platform.ArchitectureOrDefault() && m.Platform.Variant == platform.Variant {
+			return digest.Parse(m.Digest)
+		}
+	}
+	return "", fmt.Errorf("the manifest list has no image for the platform")
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/containers/image/manifest"
+	"github.com/slinkydeveloper/kfn/pkg/image"
+)
+
+const armManifestList = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+  "manifests": [
+    {"digest": "sha256:1111111111111111111111111111111111111111111111111111111111111111", "platform": {"architecture": "amd64", "os": "linux"}},
+    {"digest": "sha256:6666666666666666666666666666666666666666666666666666666666666666", "platform": {"architecture": "arm", "os": "linux", "variant": "v6"}},
+    {"digest": "sha256:7777777777777777777777777777777777777777777777777777777777777777", "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}}
+  ]
+}`
+
+func TestFindPlatformManifest(t *testing.T) {
+	tests := []struct {
+		platform image.Platform
+		expected string
+	}{
+		{platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}, expected: "sha256:6666666666666666666666666666666666666666666666666666666666666666"},
+		{platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, expected: "sha256:7777777777777777777777777777777777777777777777777777777777777777"},
+		{platform: image.Platform{OS: "linux", Architecture: "arm", Variant: "v8"}},
+	}
+	for _, test := range tests {
+		actual, err := findPlatformManifest([]byte(armManifestList), manifest.DockerV2ListMediaType, test.platform)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("expected no manifest for %s, got %s", test.platform, actual)
+			}
+			continue
+		}
+		if err != nil || actual.String() != test.expected {
+			t.Errorf("expected %s for %s, got %s (%v)", test.expected, test.platform, actual, err)
+		}
+	}
+}
+
+func TestFindPlatformManifestRequiresManifestList(t *testing.T) {
+	_, err := findPlatformManifest([]byte(`{"schemaVersion": 2}`), manifest.DockerV2Schema2MediaType, image.Platform{OS: "linux", Architecture: "arm", Variant: "v7"})
+	if err == nil {
+		t.Error("expected a single image manifest to be refused")
+	}
+}
@@ -0,0 +1,107 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containers/buildah"
+	"github.com/containers/image/docker/reference"
+	"github.com/containers/image/manifest"
+	"github.com/containers/image/transports/alltransports"
+	"github.com/containers/image/types"
+	"github.com/containers/storage/pkg/archive"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Owner of the files added to the image in reproducible builds, so the image doesn't depend on the user running kfn
+const reproducibleOwner = "0:0"
+
+// PinImage resolves the tag of the image in the registry, returning the image name pinned to the manifest digest.
+// Images already pinned are returned as they are
+func PinImage(ctx context.Context, systemContext *types.SystemContext, name string) (string, error) {
+	if name == "" || strings.Contains(name, "@") {
+		return name, nil
+	}
+
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return "", err
+	}
+	ref, err := alltransports.ParseImageName("docker://" + reference.TagNameOnly(named).String())
+	if err != nil {
+		return "", err
+	}
+
+	src, err := ref.NewImageSource(ctx, systemContext)
+	if err != nil {
+		return "", fmt.Errorf("cannot pin the image %s: %v", name, err)
+	}
+	defer src.Close()
+
+	rawManifest, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot pin the image %s: %v", name, err)
+	}
+	manifestDigest, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return "", err
+	}
+
+	pinned, err := reference.WithDigest(reference.TrimNamed(named), manifestDigest)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("Pinned image %s to %s", name, pinned)
+	return pinned.String(), nil
+}
+
+// normalizeChanges sets the modification time of the files changed in the builder container to sourceDate,
+// so the layer doesn't depend on when the files were added or generated by the build commands.
+// The layer diff is already written in lexical order, the timestamps are the only varying part
+func normalizeChanges(builder *buildah.Builder, sourceDate time.Time) error {
+	store, err := getStore()
+	if err != nil {
+		return err
+	}
+
+	container, err := store.Container(builder.ContainerID)
+	if err != nil {
+		return err
+	}
+	layer, err := store.Layer(container.LayerID)
+	if err != nil {
+		return err
+	}
+	changes, err := store.Changes(layer.Parent, layer.ID)
+	if err != nil {
+		return err
+	}
+
+	mountPoint, err := builder.Mount(builder.MountLabel)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := builder.Unmount(); err != nil {
+			log.Warnf("Cannot unmount builder container %s: %v", builder.Container, err)
+		}
+	}()
+
+	times := []unix.Timespec{unix.NsecToTimespec(sourceDate.UnixNano()), unix.NsecToTimespec(sourceDate.UnixNano())}
+	for _, change := range changes {
+		if change.Kind == archive.ChangeDelete {
+			continue
+		}
+		// The changes are real paths of the layer, so only the last component can be a symlink.
+		// It's not followed, since it could point outside the container
+		p := filepath.Join(mountPoint, change.Path)
+		if err := unix.UtimesNanoAt(unix.AT_FDCWD, p, times, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return fmt.Errorf("cannot normalize the timestamp of %s: %v", change.Path, err)
+		}
+	}
+	return nil
+}
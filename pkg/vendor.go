@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/slinkydeveloper/kfn/pkg/config"
+	"github.com/slinkydeveloper/kfn/pkg/util"
+)
+
+// Vendor downloads the runtime and the dependencies of the function to the kfn directory, so the function can be built offline.
+// The vendored dependencies are shared by all the functions
+func Vendor(ctx context.Context, options BuildOptions) error {
+	if config.Offline {
+		return fmt.Errorf("the dependencies can't be vendored offline")
+	}
+
+	fn, err := prepare(ctx, options, nil)
+	if err != nil {
+		return err
+	}
+
+	err = options.runStage(ctx, StageConfigure, func() error {
+		log.Info("Configuring target directory")
+		return fn.languageManager.ConfigureTargetDirectory(fn.function, fn.configuration, fn.targetDir)
+	})
+	if err != nil {
+		return err
+	}
+
+	return options.runStage(ctx, StageVendor, func() error {
+		log.Infof("Vendoring the dependencies to %s", config.VendorDir)
+		return fn.languageManager.Vendor(ctx, fn.function, fn.configuration, fn.targetDir)
+	})
+}
+
+// The vendor archive contains the vendored dependencies and the downloaded runtimes
+func vendorArchiveDirectories() []string {
+	return []string{filepath.Base(config.VendorDir), filepath.Base(config.RuntimeDir)}
+}
+
+// ExportVendor writes the vendored dependencies and the runtimes to the tar.gz archive destination
+func ExportVendor(destination string) error {
+	return util.TarDirectories(destination, config.KfnDir, vendorArchiveDirectories()...)
+}
+
+// ImportVendor extracts in the kfn directory an archive written by ExportVendor, returning the number of imported files.
+// The archive can contain only the vendored dependencies and the runtimes, so it can't install plugins or change the configuration
+func ImportVendor(source string) (int, error) {
+	if err := util.MkdirpIfNotExists(config.KfnDir); err != nil {
+		return 0, err
+	}
+	files, err := util.Untar(source, config.KfnDir, vendorArchiveDirectories()...)
+	return len(files), err
+}
@@ -0,0 +1,54 @@
+// Command wasi-host is the runtime of the Rust functions compiled to WASI. It serves http on PORT (8080 by default)
+// and, for every request, runs the module with wasmtime passing the request body on stdin and replying with stdout.
+// The module exits with a non-zero status when the function fails, printing the error on stderr
+package main
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	wasmtime = "/usr/local/bin/wasmtime"
+	module   = "/function.wasm"
+)
+
+func main() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	http.HandleFunc("/", handle)
+	log.Printf("Serving %s on port %s", module, port)
+	log.Fatal(http.ListenAndServe(":"+port, nil))
+}
+
+func handle(w http.ResponseWriter, r *http.Request) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(r.Context(), wasmtime, module)
+	cmd.Stdin = r.Body
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		log.Printf("Function failed: %s", message)
+		http.Error(w, message, http.StatusInternalServerError)
+		return
+	}
+
+	if stdout.Len() == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(stdout.Bytes())
+}
@@ -0,0 +1,17 @@
+// Add dependencies using comments, like:
+// kfn:dependency org.apache.commons:commons-lang3 3.9
+
+import com.fasterxml.jackson.databind.JsonNode;
+import org.apache.commons.lang3.StringUtils;
+
+import java.util.Map;
+
+// Function class must be named Function and have this method
+public class Function {
+
+    public static Object function(JsonNode event) throws Exception {
+        String name = event != null && event.hasNonNull("name") ? event.get("name").asText() : "world";
+        return Map.of("Hello", StringUtils.capitalize(name));
+    }
+
+}
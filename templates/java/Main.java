@@ -0,0 +1,70 @@
+import com.fasterxml.jackson.core.JsonProcessingException;
+import com.fasterxml.jackson.databind.JsonNode;
+import com.fasterxml.jackson.databind.ObjectMapper;
+import com.sun.net.httpserver.HttpExchange;
+import com.sun.net.httpserver.HttpServer;
+
+import java.io.IOException;
+import java.io.OutputStream;
+import java.net.InetSocketAddress;
+import java.nio.charset.StandardCharsets;
+import java.util.concurrent.Executors;
+
+// Runtime wrapper generated by kfn
+public class Main {
+
+    private static final ObjectMapper MAPPER = new ObjectMapper();
+
+    public static void main(String[] args) throws IOException {
+        int port = Integer.parseInt(System.getenv().getOrDefault("PORT", "8080"));
+        HttpServer server = HttpServer.create(new InetSocketAddress(port), 0);
+        server.createContext("/", Main::handle);
+        server.setExecutor(Executors.newCachedThreadPool());
+        server.start();
+    }
+
+    private static void handle(HttpExchange exchange) throws IOException {
+        try {
+            JsonNode event;
+            try {
+                byte[] body = exchange.getRequestBody().readAllBytes();
+                event = body.length == 0 ? null : MAPPER.readTree(body);
+            } catch (JsonProcessingException e) {
+                send(exchange, 400, "text/plain", ("Event is not valid json: " + e.getMessage()).getBytes(StandardCharsets.UTF_8));
+                return;
+            }
+
+            Object result = Function.function(event);
+            if (result == null) {
+                exchange.sendResponseHeaders(204, -1);
+                return;
+            }
+
+            if (result instanceof String) {
+                send(exchange, 200, "text/plain", ((String) result).getBytes(StandardCharsets.UTF_8));
+            } else {
+                byte[] json;
+                try {
+                    json = MAPPER.writeValueAsBytes(result);
+                } catch (JsonProcessingException e) {
+                    send(exchange, 500, "text/plain", ("Result is not serializable to json: " + e.getMessage()).getBytes(StandardCharsets.UTF_8));
+                    return;
+                }
+                send(exchange, 200, "application/json", json);
+            }
+        } catch (Exception e) {
+            send(exchange, 500, "text/plain", String.valueOf(e.getMessage()).getBytes(StandardCharsets.UTF_8));
+        } finally {
+            exchange.close();
+        }
+    }
+
+    private static void send(HttpExchange exchange, int status, String contentType, byte[] payload) throws IOException {
+        exchange.getResponseHeaders().set("Content-Type", contentType);
+        exchange.sendResponseHeaders(status, payload.length);
+        try (OutputStream out = exchange.getResponseBody()) {
+            out.write(payload);
+        }
+    }
+
+}
@@ -0,0 +1,15 @@
+# Add dependencies using comments, like:
+# kfn:dependency requests 2.22.0
+
+import requests
+
+
+# Function must have this signature
+def function(event):
+    name = "World"
+    if isinstance(event, dict):
+        name = event.get("name", name)
+    return {
+        "Hello": name,
+        "requests_version": requests.__version__
+    }
@@ -0,0 +1,61 @@
+import importlib.util
+import json
+import os
+import sys
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+FUNCTION_FILE = os.environ.get("KFN_FUNCTION_FILE", "/home/app/usr/function.py")
+PORT = int(os.environ.get("PORT", "8080"))
+
+
+def load_function(location):
+    # Allow the function to import modules placed near it
+    sys.path.insert(0, os.path.dirname(location))
+    spec = importlib.util.spec_from_file_location("function", location)
+    module = importlib.util.module_from_spec(spec)
+    spec.loader.exec_module(module)
+    return module.function
+
+
+class FunctionHandler(BaseHTTPRequestHandler):
+    function = None
+
+    def do_POST(self):
+        length = int(self.headers.get("Content-Length", 0))
+        body = self.rfile.read(length) if length > 0 else b""
+        try:
+            event = json.loads(body) if body else None
+        except ValueError as e:
+            self.send_error(400, "Event is not valid json: %s" % e)
+            return
+
+        try:
+            result = FunctionHandler.function(event)
+        except Exception as e:
+            self.send_error(500, str(e))
+            return
+
+        if result is None:
+            self.send_response(204)
+            self.end_headers()
+            return
+
+        if isinstance(result, (str, bytes)):
+            payload = result if isinstance(result, bytes) else result.encode("utf-8")
+            content_type = "text/plain"
+        else:
+            payload = json.dumps(result).encode("utf-8")
+            content_type = "application/json"
+
+        self.send_response(200)
+        self.send_header("Content-Type", content_type)
+        self.send_header("Content-Length", str(len(payload)))
+        self.end_headers()
+        self.wfile.write(payload)
+
+    do_GET = do_POST
+
+
+if __name__ == "__main__":
+    FunctionHandler.function = load_function(FUNCTION_FILE)
+    HTTPServer(("", PORT), FunctionHandler).serve_forever()
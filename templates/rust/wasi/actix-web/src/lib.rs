@@ -0,0 +1,41 @@
+// Minimal replacement of actix-web used when compiling functions to wasm32-wasi,
+// so the function signature is the same of the http runtime
+use std::fmt;
+
+#[derive(Debug)]
+pub struct Error {
+    cause: String,
+}
+
+impl fmt::Display for Error {
+    fn fmt(&self, f: &mut fmt::Formatter) -> fmt::Result {
+        write!(f, "{}", self.cause)
+    }
+}
+
+impl<T: std::error::Error> From<T> for Error {
+    fn from(err: T) -> Error {
+        Error {
+            cause: err.to_string(),
+        }
+    }
+}
+
+pub mod error {
+    use super::Error;
+    use std::fmt::Display;
+
+    #[allow(non_snake_case)]
+    pub fn ErrorBadRequest<T: Display>(err: T) -> Error {
+        Error {
+            cause: err.to_string(),
+        }
+    }
+
+    #[allow(non_snake_case)]
+    pub fn ErrorInternalServerError<T: Display>(err: T) -> Error {
+        Error {
+            cause: err.to_string(),
+        }
+    }
+}
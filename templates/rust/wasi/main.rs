@@ -0,0 +1,27 @@
+// WASI runtime wrapper: the host passes the event on stdin and reads the result from stdout
+use futures::Future;
+use std::io::{self, Read};
+
+fn main() {
+    let mut input = String::new();
+    io::stdin()
+        .read_to_string(&mut input)
+        .expect("cannot read the event from stdin");
+
+    let event = if input.trim().is_empty() {
+        None
+    } else {
+        Some(serde_json::from_str(&input).expect("event is not valid json"))
+    };
+
+    match function::function(event).wait() {
+        Ok(Some(result)) => {
+            serde_json::to_writer(io::stdout(), &result).expect("cannot write the result to stdout")
+        }
+        Ok(None) => {}
+        Err(e) => {
+            eprintln!("{}", e);
+            std::process::exit(1);
+        }
+    }
+}
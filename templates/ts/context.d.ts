@@ -0,0 +1,14 @@
+// Context provided by the js runtime to the function
+interface Context {
+  // Parsed query parameters
+  query: { [name: string]: string | string[] };
+  // Parsed request body
+  body: any;
+  // Request headers
+  headers: { [name: string]: string | string[] };
+  method: string;
+  httpVersion: string;
+  // Incoming cloud event, if any
+  cloudevent?: any;
+  [key: string]: any;
+}
@@ -0,0 +1,10 @@
+// Add dependencies using comments, like:
+// kfn:dependency lodash 4.17.15
+// Types are added as dev dependencies:
+// kfn:dependency @types/lodash 4.14.144
+
+import * as _ from "lodash";
+
+export = (context: Context): string => {
+  return "Hello world! " + _.capitalize("a cool string from lodash");
+};